@@ -3,20 +3,31 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	grpcDelivery "github.com/sergeimurashev/hospital-system-api/account-service/internal/delivery/grpc"
 	handler "github.com/sergeimurashev/hospital-system-api/account-service/internal/delivery/http"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/directory"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/password"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+	"github.com/sergeimurashev/hospital-system-api/proto/account"
 )
 
 func main() {
@@ -36,17 +47,154 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Session{},
+		&domain.RefreshToken{},
+		&domain.TokenRevocation{},
+		&domain.SigningKey{},
+		&domain.ServiceClient{},
+		&domain.MFACredential{},
+		&domain.RecoveryCode{},
+		&domain.MFAChallenge{},
+		&domain.MFARequirement{},
+		&domain.LoginAttempt{},
+		&domain.PasswordResetToken{},
+		&domain.PasswordResetAttempt{},
+		&domain.VerificationCode{},
+		&domain.PasswordHistory{},
+		&domain.AuditEvent{},
+		&domain.PatientProfile{},
+		&domain.DoctorProfile{},
+	); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	if err := repository.MigrateUsernameIndex(db); err != nil {
+		log.Fatalf("Failed to migrate username index: %v", err)
+	}
+	if err := repository.EnsureAuditAppendOnly(db); err != nil {
+		log.Fatalf("Failed to protect audit log: %v", err)
+	}
+
 	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	revocationRepo := repository.NewRevocationRepository(db)
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is not set")
+	rotationInterval := 30 * 24 * time.Hour
+	if value := os.Getenv("JWT_KEY_ROTATION_INTERVAL"); value != "" {
+		rotationInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid JWT_KEY_ROTATION_INTERVAL: %v", err)
+		}
 	}
-	userService := service.NewUserService(userRepo, jwtSecret)
+
+	keyService := service.NewKeyService(repository.NewSigningKeyRepository(db), rotationInterval)
+	if err := keyService.Sync(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := keyService.Sync(); err != nil {
+				log.Printf("Failed to sync signing keys: %v", err)
+			}
+		}
+	}()
+
+	accountRetention := 30 * 24 * time.Hour
+	if value := os.Getenv("ACCOUNT_RETENTION"); value != "" {
+		accountRetention, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid ACCOUNT_RETENTION: %v", err)
+		}
+	}
+
+	pendingAccountTTL := 7 * 24 * time.Hour
+	if value := os.Getenv("PENDING_ACCOUNT_TTL"); value != "" {
+		pendingAccountTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid PENDING_ACCOUNT_TTL: %v", err)
+		}
+	}
+
+	registrationMode := domain.RegistrationOpen
+	if value := os.Getenv("REGISTRATION_MODE"); value != "" {
+		registrationMode = domain.RegistrationMode(value)
+		if !registrationMode.Valid() {
+			log.Fatalf("Invalid REGISTRATION_MODE: %q", value)
+		}
+	}
+
+	loginRepo := repository.NewLoginAttemptRepository(db)
+	notifier := newNotifier()
+	passwords := service.NewPasswords(newPasswordPolicy(), repository.NewPasswordHistoryRepository(db))
+	auditService := service.NewAuditService(repository.NewAuditRepository(db))
+
+	userService := service.NewUserService(
+		userRepo,
+		sessionRepo,
+		revocationRepo,
+		repository.NewMFARepository(db),
+		loginRepo,
+		repository.NewVerificationRepository(db),
+		passwords,
+		auditService,
+		keyService,
+		accountRetention,
+		pendingAccountTTL,
+		registrationMode,
+		notifier,
+		newAuthenticators(userRepo),
+	)
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := userService.PurgeExpiredUsers(domain.Actor{})
+			if err != nil {
+				log.Printf("Failed to purge deleted accounts: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("Purged %d deleted accounts", purged)
+			}
+			expired, err := userService.ExpirePendingUsers(domain.Actor{})
+			if err != nil {
+				log.Printf("Failed to expire pending accounts: %v", err)
+			}
+			if expired > 0 {
+				log.Printf("Expired %d pending accounts", expired)
+			}
+		}
+	}()
+	clientService := service.NewClientService(repository.NewClientRepository(db), revocationRepo, keyService)
+	resetService := service.NewPasswordResetService(
+		userRepo,
+		repository.NewPasswordResetRepository(db),
+		loginRepo,
+		userService,
+		passwords,
+		auditService,
+		notifier,
+		os.Getenv("PASSWORD_RESET_URL"),
+	)
+
+	patientService := service.NewPatientService(repository.NewPatientRepository(db), userRepo, auditService)
+	doctorService := service.NewDoctorService(repository.NewDoctorRepository(db), auditService)
+	scimService := service.NewSCIMService(userRepo, userService, passwords, auditService)
 
 	router := gin.Default()
 
-	handler := handler.NewHandler(userService)
+	handler := handler.NewHandler(
+		userService,
+		clientService,
+		resetService,
+		auditService,
+		patientService,
+		doctorService,
+		keyService,
+		scimService,
+	)
 	handler.RegisterRoutes(router)
 
 	srv := &http.Server{
@@ -60,6 +208,27 @@ func main() {
 		}
 	}()
 
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcDelivery.UnaryAuthInterceptor(userService, auditService),
+		auth.UnaryAuthorizeInterceptor(grpcDelivery.Permissions),
+	))
+	account.RegisterAccountServiceServer(grpcServer, grpcDelivery.NewServer(userService, doctorService))
+
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50054"
+	}
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	go func() {
+		log.Printf("Starting gRPC server on port %s", grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Failed to serve gRPC: %v", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -68,9 +237,135 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	grpcServer.GracefulStop()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	log.Println("Server exiting")
 }
+
+// newNotifier sends mail over SMTP when SMTP_HOST is set. Otherwise
+// messages are written to NOTIFY_LOG_FILE, or to stdout, for local
+// development.
+func newNotifier() notify.Notifier {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+	}
+
+	if path := os.Getenv("NOTIFY_LOG_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatalf("Failed to open notification log: %v", err)
+		}
+		return notify.NewLogNotifier(file)
+	}
+	return notify.NewLogNotifier(os.Stdout)
+}
+
+// newAuthenticators builds the sign-in chain named by AUTHENTICATORS, in
+// order. It defaults to local accounts only; "ldap,local" signs staff in
+// against the directory and keeps local accounts for patients.
+func newAuthenticators(userRepo repository.UserRepository) []service.Authenticator {
+	names := os.Getenv("AUTHENTICATORS")
+	if names == "" {
+		names = "local"
+	}
+
+	var authenticators []service.Authenticator
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "local":
+			authenticators = append(authenticators, service.NewLocalAuthenticator(userRepo))
+		case "ldap":
+			authenticators = append(authenticators, service.NewDirectoryAuthenticator(newLDAPDirectory()))
+		default:
+			log.Fatalf("Invalid AUTHENTICATORS entry: %q", name)
+		}
+	}
+	return authenticators
+}
+
+// newLDAPDirectory reads the LDAP_* settings. Groups are looked up with
+// LDAP_GROUP_FILTER, which defaults to groupOfNames membership, and mapped
+// to roles by LDAP_GROUP_ROLES.
+func newLDAPDirectory() directory.Directory {
+	groupRoles, err := directory.ParseGroupRoles(os.Getenv("LDAP_GROUP_ROLES"))
+	if err != nil {
+		log.Fatalf("Invalid LDAP_GROUP_ROLES: %v", err)
+	}
+
+	groupFilter, ok := os.LookupEnv("LDAP_GROUP_FILTER")
+	if !ok {
+		groupFilter = "(member=%s)"
+	}
+
+	var timeout time.Duration
+	if value := os.Getenv("LDAP_TIMEOUT"); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid LDAP_TIMEOUT: %v", err)
+		}
+	}
+
+	dir, err := directory.NewLDAP(directory.LDAPConfig{
+		URL:                os.Getenv("LDAP_URL"),
+		StartTLS:           os.Getenv("LDAP_START_TLS") == "true",
+		BindDN:             os.Getenv("LDAP_BIND_DN"),
+		BindPassword:       os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:             os.Getenv("LDAP_BASE_DN"),
+		UserFilter:         os.Getenv("LDAP_USER_FILTER"),
+		GroupBaseDN:        os.Getenv("LDAP_GROUP_BASE_DN"),
+		GroupFilter:        groupFilter,
+		UsernameAttribute:  os.Getenv("LDAP_USERNAME_ATTRIBUTE"),
+		EmailAttribute:     os.Getenv("LDAP_EMAIL_ATTRIBUTE"),
+		FirstNameAttribute: os.Getenv("LDAP_FIRST_NAME_ATTRIBUTE"),
+		LastNameAttribute:  os.Getenv("LDAP_LAST_NAME_ATTRIBUTE"),
+		GroupRoles:         groupRoles,
+		DefaultRole:        domain.Role(os.Getenv("LDAP_DEFAULT_ROLE")),
+		Timeout:            timeout,
+	})
+	if err != nil {
+		log.Fatalf("Invalid LDAP configuration: %v", err)
+	}
+	return dir
+}
+
+// newPasswordPolicy starts from the default policy and applies any
+// PASSWORD_* overrides from the environment.
+func newPasswordPolicy() *password.Policy {
+	policy := password.DefaultPolicy()
+	for name, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH":  &policy.MinLength,
+		"PASSWORD_MIN_CLASSES": &policy.MinClasses,
+		"PASSWORD_HISTORY":     &policy.HistorySize,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", name, err)
+		}
+		*target = n
+	}
+
+	policy.Blocklist = password.NewBlocklist()
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		if err := policy.Blocklist.LoadFile(path); err != nil {
+			log.Fatalf("Failed to load password blocklist: %v", err)
+		}
+	}
+	return policy
+}
@@ -10,7 +10,6 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
-	JWTSecret  string
 	Port       string
 }
 
@@ -21,7 +20,6 @@ func NewConfig() *Config {
 		DBUser:     getEnv("DB_USER", "postgres"),
 		DBPassword: getEnv("DB_PASSWORD", "postgres"),
 		DBName:     getEnv("DB_NAME", "hospital_system"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
 		Port:       getEnv("PORT", "8001"),
 	}
 }
@@ -0,0 +1,13 @@
+package grpc
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// Permissions lists who may call each AccountService method. Service
+// clients get their roles when an admin registers them.
+var Permissions = auth.Permissions{
+	"/account.AccountService/ValidateToken": auth.AnyRole,
+	"/account.AccountService/ListDoctors":   auth.AnyRole,
+	"/account.AccountService/GetUser":       {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+	"/account.AccountService/BatchGetUsers": {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+	"/account.AccountService/HasRole":       {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+}
@@ -0,0 +1,235 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+	"github.com/sergeimurashev/hospital-system-api/proto/account"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxBatchSize bounds BatchGetUsers so one call cannot load the whole
+	// table.
+	maxBatchSize = 500
+	// ListDoctors pages like GET /api/Doctors: ten doctors unless the
+	// caller sets a limit, and never more than maxListLimit.
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
+type Server struct {
+	account.UnimplementedAccountServiceServer
+	userService   service.UserService
+	doctorService service.DoctorService
+}
+
+func NewServer(userService service.UserService, doctorService service.DoctorService) *Server {
+	return &Server{
+		userService:   userService,
+		doctorService: doctorService,
+	}
+}
+
+// UnaryAuthInterceptor authenticates calls like auth.UnaryServerInterceptor
+// does, but checks tokens against account-service's own keys and
+// revocations instead of fetching them over the network. Calls made with an
+// impersonation token are written to the audit log.
+func UnaryAuthInterceptor(userService service.UserService, auditService service.AuditService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, auth.ErrMissingToken.Error())
+		}
+
+		token := bearerToken(values[0])
+		claims, err := userService.ValidateToken(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		principal := auth.NewPrincipal(claims, token)
+		if principal.IsImpersonated() {
+			actor := domain.Actor{UserID: principal.UserID, ImpersonatorID: principal.Actor.UserID}
+			if err := auditService.Record(actor, domain.AuditImpersonatedRequest, principal.UserID, info.FullMethod); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		return handler(auth.NewContext(ctx, principal), req)
+	}
+}
+
+func (s *Server) ValidateToken(ctx context.Context, req *account.ValidateTokenRequest) (*account.ValidateTokenResponse, error) {
+	claims, err := s.userService.ValidateToken(bearerToken(req.Token))
+	if err != nil {
+		return &account.ValidateTokenResponse{Active: false}, nil
+	}
+
+	response := &account.ValidateTokenResponse{
+		Active:    true,
+		UserId:    uint64(claims.UserID),
+		Roles:     rolesToProto(claims.Roles),
+		SessionId: uint64(claims.SessionID),
+		ClientId:  claims.ClientID,
+		Scopes:    strings.Fields(claims.Scope),
+		ExpiresAt: timestamppb.New(claims.ExpiresAt.Time),
+	}
+	if claims.Act != nil {
+		response.ActorUserId = uint64(claims.Act.UserID)
+	}
+	return response, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *account.GetUserRequest) (*account.User, error) {
+	users, err := s.resolveUsers([]uint64{req.Id})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, status.Error(codes.NotFound, service.ErrUserNotFound.Error())
+	}
+
+	return users[0], nil
+}
+
+func (s *Server) BatchGetUsers(ctx context.Context, req *account.BatchGetUsersRequest) (*account.BatchGetUsersResponse, error) {
+	if len(req.Ids) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids per call", maxBatchSize)
+	}
+
+	users, err := s.resolveUsers(req.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account.BatchGetUsersResponse{
+		Users: users,
+	}, nil
+}
+
+func (s *Server) ListDoctors(ctx context.Context, req *account.ListDoctorsRequest) (*account.ListDoctorsResponse, error) {
+	if req.Offset < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+	limit := int(req.Limit)
+	switch {
+	case limit == 0:
+		limit = defaultListLimit
+	case limit > maxListLimit:
+		limit = maxListLimit
+	}
+
+	page, err := s.doctorService.ListDoctors(domain.DoctorFilter{
+		Name:           req.NameFilter,
+		Specialization: req.Specialization,
+		HospitalID:     req.HospitalId,
+	}, int(req.Offset), limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	doctors := make([]*account.User, len(page.Items))
+	for i := range page.Items {
+		doctors[i] = convertDoctorToProto(&page.Items[i])
+	}
+
+	return &account.ListDoctorsResponse{
+		Doctors: doctors,
+		Total:   int32(page.Total),
+	}, nil
+}
+
+func (s *Server) HasRole(ctx context.Context, req *account.HasRoleRequest) (*account.HasRoleResponse, error) {
+	user, err := s.userService.GetUserByID(uint(req.UserId))
+	if err != nil {
+		if err == service.ErrUserNotFound {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &account.HasRoleResponse{
+		HasRole: user.HasRole(domain.Role(req.Role)),
+	}, nil
+}
+
+// resolveUsers loads the users with the given IDs, attaching the doctor
+// profile where there is one. Unknown IDs are skipped.
+func (s *Server) resolveUsers(ids []uint64) ([]*account.User, error) {
+	userIDs := make([]uint, len(ids))
+	for i, id := range ids {
+		userIDs[i] = uint(id)
+	}
+
+	users, err := s.userService.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	profiles, err := s.doctorService.GetProfiles(userIDs)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	result := make([]*account.User, len(users))
+	for i := range users {
+		result[i] = convertUserToProto(&users[i], profiles[users[i].ID])
+	}
+	return result, nil
+}
+
+func bearerToken(value string) string {
+	return strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+}
+
+func convertUserToProto(user *domain.User, profile *domain.DoctorProfile) *account.User {
+	return &account.User{
+		Id:            uint64(user.ID),
+		Username:      user.Username,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Roles:         rolesToProto(user.Roles),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		DoctorProfile: convertProfileToProto(profile),
+	}
+}
+
+// convertDoctorToProto fills in only the fields the doctor directory
+// shows, as ListDoctors is open to every caller.
+func convertDoctorToProto(doctor *domain.Doctor) *account.User {
+	return &account.User{
+		Id:            uint64(doctor.ID),
+		FirstName:     doctor.FirstName,
+		LastName:      doctor.LastName,
+		Roles:         rolesToProto(doctor.Roles),
+		DoctorProfile: convertProfileToProto(doctor.Profile),
+	}
+}
+
+func convertProfileToProto(profile *domain.DoctorProfile) *account.DoctorProfile {
+	if profile == nil {
+		return nil
+	}
+	return &account.DoctorProfile{
+		Specializations: profile.Specializations,
+		LicenseNumber:   profile.LicenseNumber,
+		HospitalIds:     profile.HospitalIDs,
+		Bio:             profile.Bio,
+	}
+}
+
+func rolesToProto(roles []domain.Role) []string {
+	result := make([]string, len(roles))
+	for i, role := range roles {
+		result[i] = string(role)
+	}
+	return result
+}
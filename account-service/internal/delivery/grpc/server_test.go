@@ -0,0 +1,198 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+	"github.com/sergeimurashev/hospital-system-api/proto/account"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeUserService accepts the tokens "Admin", "Manager", "Doctor" and
+// "User", each carrying that role, and knows the users in users.
+type fakeUserService struct {
+	service.UserService
+	users map[uint]domain.User
+}
+
+func (s *fakeUserService) ValidateToken(token string) (*auth.Claims, error) {
+	switch role := auth.Role(token); role {
+	case auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor, auth.RoleUser:
+		return &auth.Claims{
+			UserID:           1,
+			Roles:            []auth.Role{role},
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		}, nil
+	}
+	return nil, service.ErrInvalidToken
+}
+
+func (s *fakeUserService) GetUserByID(id uint) (*domain.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, service.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *fakeUserService) GetUsersByIDs(ids []uint) ([]domain.User, error) {
+	var users []domain.User
+	for _, id := range ids {
+		if user, ok := s.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// fakeDoctorService records the page ListDoctors was asked for.
+type fakeDoctorService struct {
+	service.DoctorService
+	offset, limit int
+}
+
+func (s *fakeDoctorService) ListDoctors(filter domain.DoctorFilter, offset, limit int) (*domain.DoctorPage, error) {
+	s.offset, s.limit = offset, limit
+	return &domain.DoctorPage{Items: []domain.Doctor{}}, nil
+}
+
+func (s *fakeDoctorService) GetProfiles(userIDs []uint) (map[uint]*domain.DoctorProfile, error) {
+	return map[uint]*domain.DoctorProfile{}, nil
+}
+
+// newTestClient serves the account service over an in-memory connection,
+// with the interceptors main.go installs.
+func newTestClient(t *testing.T, doctors *fakeDoctorService) account.AccountServiceClient {
+	t.Helper()
+
+	users := &fakeUserService{users: map[uint]domain.User{
+		7: {ID: 7, Username: "dr.house", Roles: []domain.Role{domain.RoleDoctor}},
+	}}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		UnaryAuthInterceptor(users, nil),
+		auth.UnaryAuthorizeInterceptor(Permissions),
+	))
+	account.RegisterAccountServiceServer(server, NewServer(users, doctors))
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return account.NewAccountServiceClient(conn)
+}
+
+func as(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestPermissions(t *testing.T) {
+	client := newTestClient(t, &fakeDoctorService{})
+	calls := map[string]func(ctx context.Context) error{
+		"ValidateToken": func(ctx context.Context) error {
+			_, err := client.ValidateToken(ctx, &account.ValidateTokenRequest{Token: "User"})
+			return err
+		},
+		"ListDoctors": func(ctx context.Context) error {
+			_, err := client.ListDoctors(ctx, &account.ListDoctorsRequest{})
+			return err
+		},
+		"GetUser": func(ctx context.Context) error {
+			_, err := client.GetUser(ctx, &account.GetUserRequest{Id: 7})
+			return err
+		},
+		"BatchGetUsers": func(ctx context.Context) error {
+			_, err := client.BatchGetUsers(ctx, &account.BatchGetUsersRequest{Ids: []uint64{7}})
+			return err
+		},
+		"HasRole": func(ctx context.Context) error {
+			_, err := client.HasRole(ctx, &account.HasRoleRequest{UserId: 7, Role: "Doctor"})
+			return err
+		},
+	}
+	staff := map[string]codes.Code{"Admin": codes.OK, "Manager": codes.OK, "Doctor": codes.OK, "User": codes.PermissionDenied}
+	anyone := map[string]codes.Code{"Admin": codes.OK, "Manager": codes.OK, "Doctor": codes.OK, "User": codes.OK}
+	for method, want := range map[string]map[string]codes.Code{
+		"ValidateToken": anyone,
+		"ListDoctors":   anyone,
+		"GetUser":       staff,
+		"BatchGetUsers": staff,
+		"HasRole":       staff,
+	} {
+		want[""] = codes.Unauthenticated
+		want["forged"] = codes.Unauthenticated
+		for token, code := range want {
+			if got := status.Code(calls[method](as(token))); got != code {
+				t.Errorf("%s as %q: got %v, want %v", method, token, got, code)
+			}
+		}
+	}
+}
+
+func TestNotFoundAndLimits(t *testing.T) {
+	doctors := &fakeDoctorService{}
+	client := newTestClient(t, doctors)
+	ctx := as("Admin")
+
+	if _, err := client.GetUser(ctx, &account.GetUserRequest{Id: 99}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetUser of a missing user: got %v, want %v", err, codes.NotFound)
+	}
+	if _, err := client.HasRole(ctx, &account.HasRoleRequest{UserId: 99, Role: "Doctor"}); status.Code(err) != codes.NotFound {
+		t.Errorf("HasRole of a missing user: got %v, want %v", err, codes.NotFound)
+	}
+
+	batch, err := client.BatchGetUsers(ctx, &account.BatchGetUsersRequest{Ids: []uint64{7, 99}})
+	if err != nil {
+		t.Fatalf("BatchGetUsers: %v", err)
+	}
+	if len(batch.Users) != 1 || batch.Users[0].Id != 7 {
+		t.Errorf("BatchGetUsers returned %v, want user 7 alone", batch.Users)
+	}
+	if _, err := client.BatchGetUsers(ctx, &account.BatchGetUsersRequest{Ids: make([]uint64, maxBatchSize+1)}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchGetUsers over the limit: got %v, want %v", err, codes.InvalidArgument)
+	}
+
+	for _, tc := range []struct {
+		limit int32
+		want  int
+	}{
+		{0, defaultListLimit},
+		{25, 25},
+		{maxListLimit + 1, maxListLimit},
+	} {
+		if _, err := client.ListDoctors(ctx, &account.ListDoctorsRequest{Limit: tc.limit}); err != nil {
+			t.Fatalf("ListDoctors: %v", err)
+		}
+		if doctors.limit != tc.want {
+			t.Errorf("ListDoctors with limit %d asked for %d doctors, want %d", tc.limit, doctors.limit, tc.want)
+		}
+	}
+	for _, req := range []*account.ListDoctorsRequest{{Limit: -1}, {Offset: -1}} {
+		if _, err := client.ListDoctors(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListDoctors(%v): got %v, want %v", req, err, codes.InvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func (h *Handler) listAuditEvents(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, count, ok := listPage(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.auditService.List(filter, from, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// exportAuditEvents streams every matching event as CSV or JSON Lines,
+// oldest first.
+func (h *Handler) exportAuditEvents(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var write func([]domain.AuditEvent) error
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write([]string{"id", "created_at", "actor_id", "actor_client_id", "impersonator_id", "target_id", "action", "details", "ip", "user_agent"}); err != nil {
+			return
+		}
+		write = func(events []domain.AuditEvent) error {
+			for _, event := range events {
+				if err := w.Write([]string{
+					strconv.FormatUint(uint64(event.ID), 10),
+					event.CreatedAt.UTC().Format(time.RFC3339Nano),
+					strconv.FormatUint(uint64(event.ActorID), 10),
+					csvCell(event.ActorClientID),
+					strconv.FormatUint(uint64(event.ImpersonatorID), 10),
+					strconv.FormatUint(uint64(event.TargetID), 10),
+					csvCell(event.Action),
+					csvCell(event.Details),
+					csvCell(event.IP),
+					csvCell(event.UserAgent),
+				}); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			return w.Error()
+		}
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		encoder := json.NewEncoder(c.Writer)
+		write = func(events []domain.AuditEvent) error {
+			for _, event := range events {
+				if err := encoder.Encode(event); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	if err := h.auditService.Export(filter, write); err != nil {
+		// Headers are already sent; all that is left is to cut the stream.
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+func auditFilter(c *gin.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{Action: c.Query("action")}
+
+	if value := c.Query("actorId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, err
+		}
+		filter.ActorID = uint(id)
+	}
+	if value := c.Query("targetId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, err
+		}
+		filter.TargetID = uint(id)
+	}
+	if value := c.Query("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = &since
+	}
+	if value := c.Query("until"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Until = &until
+	}
+	return filter, nil
+}
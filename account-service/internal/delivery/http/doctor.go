@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func (h *Handler) saveMyDoctorProfile(c *gin.Context) {
+	h.saveDoctorProfileFor(c, c.GetUint("user_id"))
+}
+
+func (h *Handler) saveDoctorProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	h.saveDoctorProfileFor(c, uint(id))
+}
+
+func (h *Handler) deleteDoctorProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.doctorService.DeleteProfile(actor(c), uint(id)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) saveDoctorProfileFor(c *gin.Context, userID uint) {
+	var req domain.DoctorProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := h.doctorService.SaveProfile(actor(c), userID, &req)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -1,23 +1,49 @@
 package http
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 )
 
 type Handler struct {
-	userService service.UserService
+	userService    service.UserService
+	clientService  service.ClientService
+	resetService   service.PasswordResetService
+	auditService   service.AuditService
+	patientService service.PatientService
+	doctorService  service.DoctorService
+	keyService     service.KeyService
+	scimService    service.SCIMService
 }
 
-func NewHandler(userService service.UserService) *Handler {
+func NewHandler(
+	userService service.UserService,
+	clientService service.ClientService,
+	resetService service.PasswordResetService,
+	auditService service.AuditService,
+	patientService service.PatientService,
+	doctorService service.DoctorService,
+	keyService service.KeyService,
+	scimService service.SCIMService,
+) *Handler {
 	return &Handler{
-		userService: userService,
+		userService:    userService,
+		clientService:  clientService,
+		resetService:   resetService,
+		auditService:   auditService,
+		patientService: patientService,
+		doctorService:  doctorService,
+		keyService:     keyService,
+		scimService:    scimService,
 	}
 }
 
@@ -28,35 +54,103 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 		})
 	})
 
+	router.GET("/.well-known/jwks.json", h.getJWKS)
+	router.POST("/oauth/token", h.issueClientToken)
+	h.registerSCIMRoutes(router)
+
 	api := router.Group("/api")
 	{
-		auth := api.Group("/Authentication")
+		authentication := api.Group("/Authentication")
+		{
+			authentication.POST("/SignUp", h.signUp)
+			authentication.POST("/Verify", h.verifyAccount)
+			authentication.POST("/Verify/Resend", h.resendVerification)
+			authentication.POST("/SignIn", h.signIn)
+			authentication.PUT("/SignOut", h.authMiddleware(), h.authorize(), h.signOut)
+			authentication.GET("/Validate", h.validateToken)
+			authentication.POST("/Refresh", h.refreshToken)
+			authentication.GET("/Revocations", h.clientTokenMiddleware(domain.ScopeRevocations), h.listRevocations)
+			authentication.POST("/MFA/Verify", h.verifyMFA)
+			authentication.POST("/MFA/Enroll", h.enrollMFAWithChallenge)
+			authentication.POST("/ChangePassword", h.changePassword)
+			authentication.POST("/PasswordReset", h.requestPasswordReset)
+			authentication.POST("/PasswordReset/Confirm", h.confirmPasswordReset)
+			authentication.POST("/Introspect", h.clientAuthMiddleware(domain.ScopeIntrospect), h.introspect)
+		}
+
+		accounts := api.Group("/Accounts", h.authMiddleware(), h.authorize())
 		{
-			auth.POST("/SignUp", h.signUp)
-			auth.POST("/SignIn", h.signIn)
-			auth.PUT("/SignOut", h.authMiddleware(), h.signOut)
-			auth.GET("/Validate", h.validateToken)
-			auth.POST("/Refresh", h.refreshToken)
+			accounts.GET("/Me", h.getAccount)
+			accounts.PUT("/Update", h.updateAccount)
+			accounts.GET("/Me/Sessions", h.listMySessions)
+			accounts.DELETE("/Me/Sessions/:sessionId", h.revokeMySession)
+			accounts.GET("/Me/Patient", h.getMyPatientProfile)
+			accounts.PUT("/Me/Patient", h.saveMyPatientProfile)
+			accounts.DELETE("/Me/Patient", h.deleteMyPatientProfile)
+			accounts.PUT("/Me/Doctor", h.saveMyDoctorProfile)
+			accounts.POST("/Me/MFA", h.enrollMFA)
+			accounts.POST("/Me/MFA/Confirm", h.confirmMFA)
+			accounts.POST("/Me/MFA/Disable", h.disableMFA)
+			accounts.POST("/Me/MFA/RecoveryCodes", h.regenerateRecoveryCodes)
+			accounts.GET("/MFAPolicy", h.getMFAPolicy)
+			accounts.PUT("/MFAPolicy", h.setMFAPolicy)
+			accounts.GET("", h.listUsers)
+			accounts.POST("", h.createUser)
+			accounts.POST("/Import", h.importUsers)
+			accounts.GET("/Export", h.exportUsers)
+			accounts.PUT("/:id", h.updateUser)
+			accounts.DELETE("/:id", h.deleteUser)
+			accounts.GET("/Deleted", h.listDeletedUsers)
+			accounts.POST("/Deleted/Purge", h.purgeExpiredUsers)
+			accounts.POST("/:id/Restore", h.restoreUser)
+			accounts.POST("/:id/Approve", h.approveUser)
+			accounts.POST("/:id/Disable", h.disableUser)
+			accounts.POST("/:id/Enable", h.enableUser)
+			accounts.DELETE("/:id/Purge", h.purgeUser)
+			accounts.POST("/:id/Roles", h.grantRole)
+			accounts.DELETE("/:id/Roles/:role", h.revokeRole)
+			accounts.POST("/:id/Revoke", h.revokeUserTokens)
+			accounts.POST("/:id/Impersonate", h.impersonateUser)
+			accounts.GET("/:id/Patient", h.getPatientProfile)
+			accounts.PUT("/:id/Patient", h.savePatientProfile)
+			accounts.DELETE("/:id/Patient", h.deletePatientProfile)
+			accounts.PUT("/:id/Doctor", h.saveDoctorProfile)
+			accounts.DELETE("/:id/Doctor", h.deleteDoctorProfile)
+			accounts.GET("/:id/Sessions", h.listUserSessions)
+			accounts.DELETE("/:id/Sessions/:sessionId", h.revokeUserSession)
+			accounts.DELETE("/:id/MFA", h.resetMFA)
+			accounts.POST("/:id/Unlock", h.unlockUser)
+			accounts.POST("/:id/RequirePasswordChange", h.requirePasswordChange)
+			accounts.GET("/LoginAttempts", h.listLoginAttempts)
 		}
 
-		accounts := api.Group("/Accounts")
+		clients := api.Group("/Clients", h.authMiddleware(), h.authorize())
 		{
-			accounts.GET("/Me", h.authMiddleware(), h.getAccount)
-			accounts.PUT("/Update", h.authMiddleware(), h.updateAccount)
-			accounts.GET("", h.adminMiddleware(), h.listUsers)
-			accounts.POST("", h.adminMiddleware(), h.createUser)
-			accounts.PUT("/:id", h.adminMiddleware(), h.updateUser)
-			accounts.DELETE("/:id", h.adminMiddleware(), h.deleteUser)
+			clients.GET("", h.listClients)
+			clients.POST("", h.createClient)
+			clients.POST("/:id/Secret", h.rotateClientSecret)
+			clients.DELETE("/:id", h.deleteClient)
 		}
 
-		doctors := api.Group("/Doctors")
+		audit := api.Group("/Audit", h.authMiddleware(), h.authorize())
 		{
-			doctors.GET("", h.authMiddleware(), h.listDoctors)
-			doctors.GET("/:id", h.authMiddleware(), h.getDoctor)
+			audit.GET("", h.listAuditEvents)
+			audit.GET("/Export", h.exportAuditEvents)
+		}
+
+		doctors := api.Group("/Doctors", h.authMiddleware(), h.authorize())
+		{
+			doctors.GET("", h.listDoctors)
+			doctors.GET("/:id", h.getDoctor)
 		}
 	}
 }
 
+func (h *Handler) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keyService.JWKS())
+}
+
 func (h *Handler) signUp(c *gin.Context) {
 	var req domain.SignUpRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -64,12 +158,50 @@ func (h *Handler) signUp(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.SignUp(&req); err != nil {
+	response, err := h.userService.SignUp(&req, clientInfo(c))
+	if err != nil {
+		if validationError(c, err) {
+			return
+		}
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func (h *Handler) verifyAccount(c *gin.Context) {
+	var req domain.VerifyAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.VerifyAccount(&req, clientInfo(c)); err != nil {
+		if err == service.ErrInvalidVerificationCode {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) resendVerification(c *gin.Context) {
+	var req domain.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.ResendVerification(&req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
 }
 
 func (h *Handler) signIn(c *gin.Context) {
@@ -79,8 +211,21 @@ func (h *Handler) signIn(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.userService.SignIn(&req)
+	tokens, err := h.userService.SignIn(&req, clientInfo(c))
 	if err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrPasswordChangeRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "password_change_required": true})
+			return
+		}
+		if pendingAccountError(c, err) || directoryError(c, err) {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -88,7 +233,122 @@ func (h *Handler) signIn(c *gin.Context) {
 	c.JSON(http.StatusOK, tokens)
 }
 
+func (h *Handler) changePassword(c *gin.Context) {
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.userService.ChangePassword(&req, clientInfo(c))
+	if err != nil {
+		if validationError(c, err) {
+			return
+		}
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if pendingAccountError(c, err) || directoryError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) verifyMFA(c *gin.Context) {
+	var req domain.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.userService.VerifyMFA(&req, clientInfo(c))
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) enrollMFAWithChallenge(c *gin.Context) {
+	var req domain.MFAChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.userService.EnrollMFAWithChallenge(req.ChallengeToken)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *Handler) requestPasswordReset(c *gin.Context) {
+	var req domain.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Username == "" && req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username or email is required"})
+		return
+	}
+
+	if err := h.resetService.RequestReset(&req, clientInfo(c)); err != nil {
+		if err == service.ErrTooManyResetRequests {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func (h *Handler) confirmPasswordReset(c *gin.Context) {
+	var req domain.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.resetService.ConfirmReset(&req, clientInfo(c)); err != nil {
+		if validationError(c, err) {
+			return
+		}
+		if err == service.ErrInvalidResetToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
 func (h *Handler) signOut(c *gin.Context) {
+	principal, _ := auth.PrincipalFromGin(c)
+	if err := h.userService.SignOut(actor(c), principal.SessionID, principal.TokenID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -108,6 +368,56 @@ func (h *Handler) validateToken(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (h *Handler) introspect(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.userService.Introspect(token))
+}
+
+// issueClientToken implements the OAuth2 client_credentials grant. Clients
+// authenticate with HTTP Basic or with client_id and client_secret form
+// fields.
+func (h *Handler) issueClientToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := h.clientService.Authenticate(clientID, secret)
+	if err != nil {
+		if err == service.ErrInvalidClient {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.clientService.IssueToken(client, c.PostForm("scope"))
+	if err != nil {
+		if err == service.ErrInvalidScope {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
 func (h *Handler) refreshToken(c *gin.Context) {
 	var req domain.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -115,7 +425,7 @@ func (h *Handler) refreshToken(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.userService.RefreshToken(&req)
+	tokens, err := h.userService.RefreshToken(&req, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -124,6 +434,16 @@ func (h *Handler) refreshToken(c *gin.Context) {
 	c.JSON(http.StatusOK, tokens)
 }
 
+func (h *Handler) listRevocations(c *gin.Context) {
+	list, err := h.userService.GetRevocationList()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
 func (h *Handler) getAccount(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	user, err := h.userService.GetUserByID(userID)
@@ -143,158 +463,884 @@ func (h *Handler) updateAccount(c *gin.Context) {
 		return
 	}
 
-	if err := h.userService.UpdateUser(userID, &req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.userService.UpdateUser(actor(c), userID, &req); err != nil {
+		if validationError(c, err) {
+			return
+		}
+		accountError(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-func (h *Handler) listUsers(c *gin.Context) {
-	from, _ := strconv.Atoi(c.DefaultQuery("from", "0"))
-	count, _ := strconv.Atoi(c.DefaultQuery("count", "10"))
-
-	users, err := h.userService.ListUsers(from, count)
+func (h *Handler) enrollMFA(c *gin.Context) {
+	enrollment, err := h.userService.EnrollMFA(actor(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		mfaError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, enrollment)
 }
 
-func (h *Handler) createUser(c *gin.Context) {
-	var req domain.CreateUserRequest
+func (h *Handler) confirmMFA(c *gin.Context) {
+	var req domain.MFACodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.userService.CreateUser(&req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	codes, err := h.userService.ConfirmMFA(actor(c), req.Code)
+	if err != nil {
+		mfaError(c, err)
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	c.JSON(http.StatusOK, domain.RecoveryCodesResponse{RecoveryCodes: codes})
 }
 
-func (h *Handler) updateUser(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+func (h *Handler) disableMFA(c *gin.Context) {
+	var req domain.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var req domain.CreateUserRequest
+	if err := h.userService.DisableMFA(actor(c), req.Code); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) regenerateRecoveryCodes(c *gin.Context) {
+	var req domain.MFACodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	updateReq := domain.UpdateUserRequest{
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Password:  req.Password,
+	codes, err := h.userService.RegenerateRecoveryCodes(actor(c), req.Code)
+	if err != nil {
+		mfaError(c, err)
+		return
 	}
 
-	if err := h.userService.UpdateUser(uint(id), &updateReq); err != nil {
+	c.JSON(http.StatusOK, domain.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) getMFAPolicy(c *gin.Context) {
+	policy, err := h.userService.GetMFAPolicy()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *Handler) setMFAPolicy(c *gin.Context) {
+	var req domain.MFAPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.SetMFAPolicy(actor(c), &req); err != nil {
+		accountError(c, err)
+		return
+	}
+
 	c.Status(http.StatusOK)
 }
 
-func (h *Handler) deleteUser(c *gin.Context) {
+func (h *Handler) resetMFA(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.userService.ResetMFA(actor(c), uint(id)); err != nil {
+		accountError(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-func (h *Handler) listDoctors(c *gin.Context) {
-	// TODO: Implement doctor listing
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "not implemented"})
-}
+func (h *Handler) listUsers(c *gin.Context) {
+	filter, err := userFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, count, ok := listPage(c)
+	if !ok {
+		return
+	}
 
-func (h *Handler) getDoctor(c *gin.Context) {
-	// TODO: Implement doctor retrieval
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "not implemented"})
+	users, err := h.userService.ListUsers(filter, from, count)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
 }
 
-func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
-			return
-		}
+func userFilter(c *gin.Context) (domain.UserFilter, error) {
+	filter := domain.UserFilter{
+		Search: c.Query("search"),
+		Role:   domain.Role(c.Query("role")),
+		Status: domain.UserStatus(c.Query("status")),
+		Sort:   c.Query("sort"),
+	}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
+	if value := c.Query("createdFrom"); value != "" {
+		createdFrom, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, err
 		}
-
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(h.userService.GetJWTSecret()), nil
-		})
-
+		filter.CreatedFrom = &createdFrom
+	}
+	if value := c.Query("createdTo"); value != "" {
+		createdTo, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+			return filter, err
 		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userID := uint(claims["user_id"].(float64))
-			role := claims["role"].(string)
-
-			c.Set("user_id", userID)
-			c.Set("role", role)
-			c.Next()
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
+		filter.CreatedTo = &createdTo
+	}
+	if value := c.Query("includeDeleted"); value != "" {
+		includeDeleted, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, err
 		}
+		filter.IncludeDeleted = includeDeleted
 	}
+	return filter, nil
 }
 
-func (h *Handler) adminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// First check authentication
-		h.authMiddleware()(c)
-		if c.IsAborted() {
-			return
-		}
+func (h *Handler) createUser(c *gin.Context) {
+	var req domain.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		// Then check if user is admin
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "role not found in token"})
-			c.Abort()
+	if err := h.userService.CreateUser(actor(c), &req); err != nil {
+		if validationError(c, err) {
 			return
 		}
+		accountError(c, err)
+		return
+	}
 
-		if role != "Admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
-			c.Abort()
-			return
+	c.Status(http.StatusCreated)
+}
+
+func (h *Handler) updateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req domain.AdminUpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.AdminUpdateUser(actor(c), uint(id), &req); err != nil {
+		if validationError(c, err) {
+			return
 		}
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
 
+func (h *Handler) grantRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req domain.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.userService.GrantRole(actor(c), uint(id), req.Role); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) revokeRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.RevokeRole(actor(c), uint(id), domain.Role(c.Param("role"))); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) deleteUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.DeleteUser(actor(c), uint(id)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) listDeletedUsers(c *gin.Context) {
+	from, count, ok := listPage(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.userService.ListDeletedUsers(from, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) restoreUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	// The body is optional; without one the account keeps its old name.
+	var req domain.RestoreUserRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.userService.RestoreUser(actor(c), uint(id), &req); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) purgeUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.PurgeUser(actor(c), uint(id)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) purgeExpiredUsers(c *gin.Context) {
+	purged, err := h.userService.PurgeExpiredUsers(actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "purged": purged})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+func (h *Handler) revokeUserTokens(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.RevokeUserTokens(actor(c), uint(id)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) impersonateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req domain.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.userService.Impersonate(actor(c), uint(id), &req)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+func (h *Handler) listMySessions(c *gin.Context) {
+	principal, _ := auth.PrincipalFromGin(c)
+	sessions, err := h.userService.ListSessions(principal.UserID, principal.SessionID)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *Handler) revokeMySession(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := h.userService.RevokeSession(actor(c), c.GetUint("user_id"), uint(sessionID)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) listUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	sessions, err := h.userService.ListSessions(uint(id), 0)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *Handler) revokeUserSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := h.userService.RevokeSession(actor(c), uint(id), uint(sessionID)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) listClients(c *gin.Context) {
+	clients, err := h.clientService.ListClients()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+func (h *Handler) createClient(c *gin.Context) {
+	var req domain.CreateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials, err := h.clientService.CreateClient(&req)
+	if err != nil {
+		if err == service.ErrInvalidRole || err == service.ErrClientAdminRole {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, credentials)
+}
+
+func (h *Handler) rotateClientSecret(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	credentials, err := h.clientService.RotateSecret(uint(id))
+	if err != nil {
+		if err == service.ErrClientNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+func (h *Handler) deleteClient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.clientService.DeleteClient(uint(id)); err != nil {
+		if err == service.ErrClientNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) unlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.UnlockUser(actor(c), uint(id)); err != nil {
+		if err == service.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) approveUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.ApproveUser(actor(c), uint(id)); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) disableUser(c *gin.Context) {
+	h.setUserActive(c, false)
+}
+
+func (h *Handler) enableUser(c *gin.Context) {
+	h.setUserActive(c, true)
+}
+
+func (h *Handler) setUserActive(c *gin.Context, active bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.SetUserActive(actor(c), uint(id), active); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) requirePasswordChange(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.userService.RequirePasswordChange(actor(c), uint(id)); err != nil {
+		if err == service.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err == service.ErrDirectoryAccount {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) listLoginAttempts(c *gin.Context) {
+	from, count, ok := listPage(c)
+	if !ok {
+		return
+	}
+
+	attempts, err := h.userService.ListLoginAttempts(c.Query("username"), c.Query("ip"), from, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+func (h *Handler) listDoctors(c *gin.Context) {
+	filter := domain.DoctorFilter{
+		Name:           c.Query("nameFilter"),
+		Specialization: c.Query("specialization"),
+	}
+	if value := c.Query("hospitalId"); value != "" {
+		hospitalID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hospital id"})
+			return
+		}
+		filter.HospitalID = hospitalID
+	}
+	from, count, ok := listPage(c)
+	if !ok {
+		return
+	}
+
+	doctors, err := h.doctorService.ListDoctors(filter, from, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, doctors)
+}
+
+func (h *Handler) getDoctor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	doctor, err := h.doctorService.GetDoctor(uint(id))
+	if err != nil {
+		if err == service.ErrDoctorNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, doctor)
+}
+
+func (h *Handler) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			c.Abort()
+			return
+		}
+
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.Abort()
+			return
+		}
+
+		claims, err := h.userService.ValidateToken(parts[1])
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		principal := auth.NewPrincipal(claims, parts[1])
+		auth.SetPrincipal(c, principal)
+		if principal.IsImpersonated() {
+			details := c.Request.Method + " " + c.Request.URL.Path
+			if err := h.auditService.Record(actor(c), domain.AuditImpersonatedRequest, principal.UserID, details); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// clientAuthMiddleware authenticates a registered service client with HTTP
+// Basic credentials and requires it to hold the given scope.
+func (h *Handler) clientAuthMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, secret, _ := c.Request.BasicAuth()
+		client, err := h.clientService.Authenticate(clientID, secret)
+		if err != nil {
+			if err != service.ErrInvalidClient {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="account-service"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			c.Abort()
+			return
+		}
+
+		if !client.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+			c.Abort()
+			return
+		}
+
+		c.Set("client_id", clientID)
+		c.Next()
+	}
+}
+
+// clientTokenMiddleware admits service clients that present an access
+// token from /oauth/token carrying scope.
+func (h *Handler) clientTokenMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="account-service"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": auth.ErrMissingToken.Error()})
+			c.Abort()
+			return
+		}
+
+		claims, err := h.userService.ValidateToken(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="account-service", error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			c.Abort()
+			return
+		}
+
+		principal := auth.NewPrincipal(claims, token)
+		if principal.ClientID == "" || !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+			c.Abort()
+			return
+		}
+
+		auth.SetPrincipal(c, principal)
 		c.Next()
 	}
 }
+
+func (h *Handler) authorize() gin.HandlerFunc {
+	return auth.Authorize(permissions)
+}
+
+// maxListCount caps the count of a listing page.
+const maxListCount = 100
+
+// listPage reads the from offset and the count of a listing, answering 400
+// for values that are not numbers or are negative. Counts above
+// maxListCount are lowered to it.
+func listPage(c *gin.Context) (int, int, bool) {
+	from, err := strconv.Atoi(c.DefaultQuery("from", "0"))
+	if err != nil || from < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a number of at least 0"})
+		return 0, 0, false
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil || count < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be a number of at least 0"})
+		return 0, 0, false
+	}
+	if count > maxListCount {
+		count = maxListCount
+	}
+	return from, count, true
+}
+
+// csvFormulaPrefixes are the first characters that make a spreadsheet read a
+// cell as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvCell keeps a value of a CSV export from running as a formula when the
+// file is opened in a spreadsheet, by putting an apostrophe before values
+// that start with =, +, -, @, a tab or a carriage return.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvValue undoes csvCell for a cell read back from an export.
+func csvValue(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// validationError answers 422 with the per-field problems if err is a
+// validation failure, and reports whether it did.
+func validationError(c *gin.Context, err error) bool {
+	var invalid *service.ValidationError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "validation failed", "fields": invalid.Fields})
+	return true
+}
+
+// accountError maps the errors of account management calls to statuses.
+func accountError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrUserNotFound, service.ErrSessionNotFound, service.ErrPatientProfileNotFound,
+		service.ErrDoctorNotFound, service.ErrDoctorProfileNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case service.ErrUserAlreadyExists, service.ErrLastAdmin, service.ErrRetentionNotElapsed,
+		service.ErrAccountNotPending, service.ErrDirectoryAccount, service.ErrNotPatient:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case service.ErrImpersonationForbidden, service.ErrCannotImpersonate:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrInvalidRole, service.ErrInvalidSort, service.ErrInvalidStatus:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// pendingAccountError answers 403 if err says the account cannot sign in
+// until it is verified, approved or enabled, and reports whether it did.
+func pendingAccountError(c *gin.Context, err error) bool {
+	switch err {
+	case service.ErrVerificationRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "verification_required": true})
+	case service.ErrApprovalPending:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "approval_pending": true})
+	case service.ErrAccountDisabled:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "account_disabled": true})
+	default:
+		return false
+	}
+	return true
+}
+
+// directoryError answers for the sign-in errors of directory accounts and
+// reports whether it did.
+func directoryError(c *gin.Context, err error) bool {
+	switch err {
+	case service.ErrDirectoryUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case service.ErrNoDirectoryRole:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrAccountSourceConflict, service.ErrDirectoryAccount:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+func mfaError(c *gin.Context, err error) {
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch err {
+	case service.ErrInvalidChallenge, service.ErrInvalidMFACode:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case service.ErrMFAAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case service.ErrMFANotEnrolled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case service.ErrImpersonationForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// actor identifies the authenticated caller of the request for the audit
+// log.
+func actor(c *gin.Context) domain.Actor {
+	a := clientInfo(c).As(0)
+	if principal, ok := auth.PrincipalFromGin(c); ok {
+		a.UserID = principal.UserID
+		a.SessionID = principal.SessionID
+		a.ClientID = principal.ClientID
+		if principal.Actor != nil {
+			a.ImpersonatorID = principal.Actor.UserID
+		}
+	}
+	return a
+}
+
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		DeviceID:  c.GetHeader("X-Device-ID"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
@@ -0,0 +1,27 @@
+package http
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"":                         "",
+		"Mozilla/5.0":              "Mozilla/5.0",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1":                       "'+1",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"\r=1":                     "'\r=1",
+		"a=1":                      "a=1",
+	} {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", value, got, want)
+		}
+		if got := csvValue(want); got != value {
+			t.Errorf("csvValue(%q) = %q, want %q", want, got, value)
+		}
+	}
+	if got := csvValue("'quoted"); got != "'quoted" {
+		t.Errorf("csvValue(%q) = %q, want it unchanged", "'quoted", got)
+	}
+}
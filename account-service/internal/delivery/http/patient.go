@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func (h *Handler) getMyPatientProfile(c *gin.Context) {
+	h.writePatientProfile(c, c.GetUint("user_id"))
+}
+
+func (h *Handler) saveMyPatientProfile(c *gin.Context) {
+	h.savePatientProfileFor(c, c.GetUint("user_id"))
+}
+
+func (h *Handler) deleteMyPatientProfile(c *gin.Context) {
+	h.deletePatientProfileFor(c, c.GetUint("user_id"))
+}
+
+func (h *Handler) getPatientProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	h.writePatientProfile(c, uint(id))
+}
+
+func (h *Handler) savePatientProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	h.savePatientProfileFor(c, uint(id))
+}
+
+func (h *Handler) deletePatientProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	h.deletePatientProfileFor(c, uint(id))
+}
+
+func (h *Handler) writePatientProfile(c *gin.Context, userID uint) {
+	profile, err := h.patientService.GetProfile(userID)
+	if err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *Handler) savePatientProfileFor(c *gin.Context, userID uint) {
+	var req domain.PatientProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := h.patientService.SaveProfile(actor(c), userID, &req)
+	if err != nil {
+		if validationError(c, err) {
+			return
+		}
+		accountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *Handler) deletePatientProfileFor(c *gin.Context, userID uint) {
+	if err := h.patientService.DeleteProfile(actor(c), userID); err != nil {
+		accountError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
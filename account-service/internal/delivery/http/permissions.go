@@ -0,0 +1,62 @@
+package http
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// permissions lists who may call each authenticated route.
+var permissions = auth.Permissions{
+	"PUT /api/Authentication/SignOut": auth.AnyRole,
+
+	"GET /api/Accounts/Me":                         auth.AnyRole,
+	"PUT /api/Accounts/Update":                     auth.AnyRole,
+	"GET /api/Accounts/Me/Sessions":                auth.AnyRole,
+	"DELETE /api/Accounts/Me/Sessions/:sessionId":  auth.AnyRole,
+	"GET /api/Accounts/Me/Patient":                 auth.AnyRole,
+	"PUT /api/Accounts/Me/Patient":                 auth.AnyRole,
+	"DELETE /api/Accounts/Me/Patient":              auth.AnyRole,
+	"PUT /api/Accounts/Me/Doctor":                  {auth.RoleDoctor},
+	"POST /api/Accounts/Me/MFA":                    auth.AnyRole,
+	"POST /api/Accounts/Me/MFA/Confirm":            auth.AnyRole,
+	"POST /api/Accounts/Me/MFA/Disable":            auth.AnyRole,
+	"POST /api/Accounts/Me/MFA/RecoveryCodes":      auth.AnyRole,
+	"GET /api/Accounts":                            {auth.RoleAdmin},
+	"POST /api/Accounts":                           {auth.RoleAdmin},
+	"POST /api/Accounts/Import":                    {auth.RoleAdmin},
+	"GET /api/Accounts/Export":                     {auth.RoleAdmin},
+	"PUT /api/Accounts/:id":                        {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id":                     {auth.RoleAdmin},
+	"GET /api/Accounts/Deleted":                    {auth.RoleAdmin},
+	"POST /api/Accounts/Deleted/Purge":             {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Restore":               {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Approve":               {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Disable":               {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Enable":                {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/Purge":               {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Revoke":                {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Impersonate":           {auth.RoleAdmin},
+	"GET /api/Accounts/:id/Patient":                {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+	"PUT /api/Accounts/:id/Patient":                {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/Patient":             {auth.RoleAdmin},
+	"PUT /api/Accounts/:id/Doctor":                 {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/Doctor":              {auth.RoleAdmin},
+	"GET /api/Accounts/:id/Sessions":               {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/Sessions/:sessionId": {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Roles":                 {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/Roles/:role":         {auth.RoleAdmin},
+	"DELETE /api/Accounts/:id/MFA":                 {auth.RoleAdmin},
+	"POST /api/Accounts/:id/Unlock":                {auth.RoleAdmin},
+	"POST /api/Accounts/:id/RequirePasswordChange": {auth.RoleAdmin},
+	"GET /api/Accounts/LoginAttempts":              {auth.RoleAdmin},
+	"GET /api/Accounts/MFAPolicy":                  {auth.RoleAdmin},
+	"PUT /api/Accounts/MFAPolicy":                  {auth.RoleAdmin},
+
+	"GET /api/Clients":             {auth.RoleAdmin},
+	"POST /api/Clients":            {auth.RoleAdmin},
+	"POST /api/Clients/:id/Secret": {auth.RoleAdmin},
+	"DELETE /api/Clients/:id":      {auth.RoleAdmin},
+
+	"GET /api/Audit":        {auth.RoleAdmin},
+	"GET /api/Audit/Export": {auth.RoleAdmin},
+
+	"GET /api/Doctors":     auth.AnyRole,
+	"GET /api/Doctors/:id": auth.AnyRole,
+}
@@ -0,0 +1,401 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/scim"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+)
+
+const (
+	scimContentType  = "application/scim+json; charset=utf-8"
+	scimDefaultCount = 100
+)
+
+func (h *Handler) registerSCIMRoutes(router *gin.Engine) {
+	discovery := router.Group("/scim/v2")
+	{
+		discovery.GET("/ServiceProviderConfig", h.scimServiceProviderConfig)
+		discovery.GET("/ResourceTypes", h.scimResourceTypes)
+	}
+
+	resources := router.Group("/scim/v2", h.scimAuthMiddleware())
+	{
+		resources.GET("/Users", h.scimListUsers)
+		resources.POST("/Users", h.scimCreateUser)
+		resources.GET("/Users/:id", h.scimGetUser)
+		resources.PUT("/Users/:id", h.scimReplaceUser)
+		resources.PATCH("/Users/:id", h.scimPatchUser)
+		resources.DELETE("/Users/:id", h.scimDeleteUser)
+		resources.GET("/Groups", h.scimListGroups)
+		resources.POST("/Groups", h.scimFixedGroups)
+		resources.GET("/Groups/:id", h.scimGetGroup)
+		resources.PUT("/Groups/:id", h.scimReplaceGroup)
+		resources.PATCH("/Groups/:id", h.scimPatchGroup)
+		resources.DELETE("/Groups/:id", h.scimFixedGroups)
+	}
+}
+
+func (h *Handler) scimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": 200},
+		"changePassword": gin.H{"supported": true},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "A client_credentials token from /oauth/token with the scim scope",
+			"primary":     true,
+		}},
+	})
+}
+
+func (h *Handler) scimResourceTypes(c *gin.Context) {
+	scimJSON(c, http.StatusOK, &domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMListResponseSchema},
+		TotalResults: 2,
+		StartIndex:   1,
+		ItemsPerPage: 2,
+		Resources: []interface{}{
+			gin.H{
+				"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+				"id":       "User",
+				"name":     "User",
+				"endpoint": "/Users",
+				"schema":   domain.SCIMUserSchema,
+			},
+			gin.H{
+				"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+				"id":       "Group",
+				"name":     "Group",
+				"endpoint": "/Groups",
+				"schema":   domain.SCIMGroupSchema,
+			},
+		},
+	})
+}
+
+func (h *Handler) scimListUsers(c *gin.Context) {
+	startIndex, count, ok := scimPage(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.scimService.ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	for _, resource := range list.Resources {
+		user := resource.(*domain.SCIMUser)
+		user.Meta.Location = scimLocation(c, "Users", user.ID)
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+func (h *Handler) scimGetUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.scimService.GetUser(id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	h.scimUser(c, http.StatusOK, user)
+}
+
+func (h *Handler) scimCreateUser(c *gin.Context) {
+	var req domain.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	user, err := h.scimService.CreateUser(actor(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Header("Location", scimLocation(c, "Users", user.ID))
+	h.scimUser(c, http.StatusCreated, user)
+}
+
+func (h *Handler) scimReplaceUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(actor(c), id, &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	h.scimUser(c, http.StatusOK, user)
+}
+
+func (h *Handler) scimPatchUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	user, err := h.scimService.PatchUser(actor(c), id, &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	h.scimUser(c, http.StatusOK, user)
+}
+
+func (h *Handler) scimDeleteUser(c *gin.Context) {
+	id, ok := scimUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scimService.DeleteUser(actor(c), id); err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) scimListGroups(c *gin.Context) {
+	startIndex, count, ok := scimPage(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.scimService.ListGroups(c.Query("filter"), startIndex, count, scimWantsMembers(c))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	for _, resource := range list.Resources {
+		group := resource.(*domain.SCIMGroup)
+		group.Meta.Location = scimLocation(c, "Groups", group.ID)
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+func (h *Handler) scimGetGroup(c *gin.Context) {
+	group, err := h.scimService.GetGroup(c.Param("id"), scimWantsMembers(c))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	group.Meta.Location = scimLocation(c, "Groups", group.ID)
+	scimJSON(c, http.StatusOK, group)
+}
+
+func (h *Handler) scimReplaceGroup(c *gin.Context) {
+	var req domain.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(actor(c), c.Param("id"), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	group.Meta.Location = scimLocation(c, "Groups", group.ID)
+	scimJSON(c, http.StatusOK, group)
+}
+
+// scimPatchGroup answers 204 rather than the group, which would list every
+// account holding the role.
+func (h *Handler) scimPatchGroup(c *gin.Context) {
+	var req domain.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	if err := h.scimService.PatchGroup(actor(c), c.Param("id"), &req); err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// scimFixedGroups refuses to create or delete groups, which are the roles
+// the services know about.
+func (h *Handler) scimFixedGroups(c *gin.Context) {
+	scimErrorResponse(c, http.StatusNotImplemented, "", "groups are the fixed set of roles; only their members can change")
+}
+
+// scimAuthMiddleware admits service clients whose access token from
+// /oauth/token carries the scim scope.
+func (h *Handler) scimAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			scimErrorResponse(c, http.StatusUnauthorized, "", "a bearer token is required")
+			c.Abort()
+			return
+		}
+
+		claims, err := h.userService.ValidateToken(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			scimErrorResponse(c, http.StatusUnauthorized, "", "invalid token")
+			c.Abort()
+			return
+		}
+
+		principal := auth.NewPrincipal(claims, token)
+		if principal.ClientID == "" || !principal.HasScope(domain.ScopeSCIM) {
+			scimErrorResponse(c, http.StatusForbidden, "", "the token does not carry the scim scope")
+			c.Abort()
+			return
+		}
+
+		auth.SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+func (h *Handler) scimUser(c *gin.Context, status int, user *domain.SCIMUser) {
+	user.Meta.Location = scimLocation(c, "Users", user.ID)
+	scimJSON(c, status, user)
+}
+
+// scimPage reads the 1-based startIndex and the count of a listing.
+func scimPage(c *gin.Context) (int, int, bool) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidValue", "startIndex must be a number")
+		return 0, 0, false
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)))
+	if err != nil {
+		scimErrorResponse(c, http.StatusBadRequest, "invalidValue", "count must be a number")
+		return 0, 0, false
+	}
+	return startIndex, count, true
+}
+
+// scimUserID reads the id of a User. Ids that are not numbers cannot
+// exist.
+func scimUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		scimErrorResponse(c, http.StatusNotFound, "", service.ErrUserNotFound.Error())
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// scimWantsMembers reports whether the attributes and excludedAttributes
+// parameters leave members in the response.
+func scimWantsMembers(c *gin.Context) bool {
+	has := func(list string) bool {
+		for _, attr := range strings.Split(list, ",") {
+			if strings.EqualFold(strings.TrimSpace(attr), "members") {
+				return true
+			}
+		}
+		return false
+	}
+	if attributes := c.Query("attributes"); attributes != "" && !has(attributes) {
+		return false
+	}
+	return !has(c.Query("excludedAttributes"))
+}
+
+func scimLocation(c *gin.Context, resource, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2/" + resource + "/" + id
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// scimError maps the errors of SCIM calls to SCIM error responses.
+func scimError(c *gin.Context, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		fields := make([]string, 0, len(invalid.Fields))
+		for field, problems := range invalid.Fields {
+			fields = append(fields, field+" "+strings.Join(problems, ", "))
+		}
+		sort.Strings(fields)
+		scimErrorResponse(c, http.StatusBadRequest, "invalidValue", strings.Join(fields, "; "))
+	case errors.Is(err, scim.ErrInvalidFilter):
+		scimErrorResponse(c, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, scim.ErrInvalidPath):
+		scimErrorResponse(c, http.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, service.ErrSCIMInvalidValue), err == service.ErrInvalidRole:
+		scimErrorResponse(c, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, service.ErrSCIMMutability), err == service.ErrDirectoryAccount:
+		scimErrorResponse(c, http.StatusBadRequest, "mutability", err.Error())
+	case err == service.ErrUserNotFound, err == service.ErrGroupNotFound:
+		scimErrorResponse(c, http.StatusNotFound, "", err.Error())
+	case err == service.ErrUserAlreadyExists:
+		scimErrorResponse(c, http.StatusConflict, "uniqueness", err.Error())
+	case err == service.ErrLastAdmin:
+		scimErrorResponse(c, http.StatusConflict, "", err.Error())
+	case err == service.ErrSCIMAdminAccount:
+		scimErrorResponse(c, http.StatusForbidden, "", err.Error())
+	default:
+		scimErrorResponse(c, http.StatusInternalServerError, "", err.Error())
+	}
+}
+
+func scimErrorResponse(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, &domain.SCIMError{
+		Schemas:  []string{domain.SCIMErrorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/service"
+)
+
+// maxImportBodySize bounds the upload of a bulk import.
+const maxImportBodySize = 10 << 20
+
+// userExportColumns are the CSV columns of an export. An import reads the
+// same file back, ignoring the columns it does not use.
+var userExportColumns = []string{"id", "username", "email", "first_name", "last_name", "roles", "status", "must_change_password", "created_at"}
+
+// importUsers creates accounts from a CSV or JSON Lines upload. Nothing is
+// created if any row is invalid; the response then lists each row's
+// problems.
+func (h *Handler) importUsers(c *gin.Context) {
+	var opts domain.ImportOptions
+	for name, target := range map[string]*bool{
+		"dryRun":        &opts.DryRun,
+		"sendPasswords": &opts.SendPasswords,
+	} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %v", name, err)})
+			return
+		}
+		*target = parsed
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+	var (
+		rows []domain.ImportUserRow
+		err  error
+	)
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		rows, err = readImportCSV(body)
+	case "jsonl":
+		rows, err = readImportJSONL(body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("the upload is larger than %d bytes", tooLarge.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.userService.ImportUsers(actor(c), rows, opts)
+	if err != nil {
+		if validationError(c, err) {
+			return
+		}
+		if err == service.ErrImportTooLarge {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		accountError(c, err)
+		return
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	case result.DryRun:
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+// exportUsers streams every matching account with its roles as CSV or JSON
+// Lines. It takes the same filters as the listing.
+func (h *Handler) exportUsers(c *gin.Context) {
+	filter, err := userFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		accountError(c, service.ErrInvalidRole)
+		return
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		accountError(c, service.ErrInvalidStatus)
+		return
+	}
+
+	var write func([]domain.User) error
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(userExportColumns); err != nil {
+			return
+		}
+		write = func(users []domain.User) error {
+			for _, user := range users {
+				roles := make([]string, 0, len(user.Roles))
+				for _, role := range user.Roles {
+					roles = append(roles, string(role))
+				}
+				if err := w.Write([]string{
+					strconv.FormatUint(uint64(user.ID), 10),
+					csvCell(user.Username),
+					csvCell(user.Email),
+					csvCell(user.FirstName),
+					csvCell(user.LastName),
+					csvCell(strings.Join(roles, ";")),
+					string(user.Status),
+					strconv.FormatBool(user.MustChangePassword),
+					user.CreatedAt.UTC().Format(time.RFC3339Nano),
+				}); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			return w.Error()
+		}
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="users.jsonl"`)
+		encoder := json.NewEncoder(c.Writer)
+		write = func(users []domain.User) error {
+			for _, user := range users {
+				if err := encoder.Encode(user); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	if err := h.userService.ExportUsers(filter, write); err != nil {
+		// Headers are already sent; all that is left is to cut the stream.
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// readImportCSV reads rows by the names in the header line. Roles are
+// separated by semicolons. The apostrophe an export puts before formula
+// characters is dropped again, except from passwords, which are never
+// exported.
+func readImportCSV(r io.Reader) ([]domain.ImportUserRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"username", "first_name", "last_name", "roles"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	var rows []domain.ImportUserRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := domain.ImportUserRow{
+			Username:  csvValue(get("username")),
+			Email:     csvValue(get("email")),
+			FirstName: csvValue(get("first_name")),
+			LastName:  csvValue(get("last_name")),
+			Password:  get("password"),
+		}
+		for _, role := range strings.Split(csvValue(get("roles")), ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				row.Roles = append(row.Roles, domain.Role(role))
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readImportJSONL reads one JSON object per line, skipping blank lines.
+func readImportJSONL(r io.Reader) ([]domain.ImportUserRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var rows []domain.ImportUserRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var row domain.ImportUserRow
+		if err := json.Unmarshal(text, &row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
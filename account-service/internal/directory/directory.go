@@ -0,0 +1,34 @@
+// Package directory checks staff credentials against an external account
+// directory such as LDAP or Active Directory.
+package directory
+
+import (
+	"errors"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found in directory")
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+)
+
+// Entry is a directory account whose password has checked out.
+type Entry struct {
+	DN        string
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	// Groups are the DNs of the groups the account belongs to, and Roles
+	// the roles they map to.
+	Groups []string
+	Roles  []domain.Role
+}
+
+type Directory interface {
+	// Authenticate returns ErrUserNotFound when no account has the
+	// username and ErrInvalidCredentials when the password is wrong. Any
+	// other error means the directory could not be asked.
+	Authenticate(username, password string) (*Entry, error)
+}
@@ -0,0 +1,281 @@
+package directory
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+type LDAPConfig struct {
+	// URL is an ldap:// or ldaps:// address. StartTLS upgrades an ldap://
+	// connection before anything is sent.
+	URL      string
+	StartTLS bool
+	// BindDN and BindPassword are the service account used to look users
+	// up. An empty BindDN searches anonymously.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds an account by username, which replaces %s. Active
+	// Directory uses (sAMAccountName=%s).
+	UserFilter string
+	// GroupFilter finds the groups an account is a member of, with its DN
+	// in place of %s. Groups listed in the account's memberOf attribute
+	// count as well. An empty GroupFilter relies on memberOf alone.
+	GroupBaseDN string
+	GroupFilter string
+
+	UsernameAttribute  string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+
+	// GroupRoles maps group DNs to the roles their members get.
+	// DefaultRole, when set, is given to accounts in none of the groups.
+	GroupRoles  []GroupRole
+	DefaultRole domain.Role
+	Timeout     time.Duration
+}
+
+type GroupRole struct {
+	GroupDN string
+	Role    domain.Role
+}
+
+// ParseGroupRoles reads a mapping written as
+// "Admin=cn=admins,ou=groups,dc=example,dc=org;Doctor=cn=doctors,...".
+func ParseGroupRoles(value string) ([]GroupRole, error) {
+	var mapping []GroupRole
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		role, dn, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("group role %q is not Role=DN", pair)
+		}
+		mapping = append(mapping, GroupRole{
+			GroupDN: strings.TrimSpace(dn),
+			Role:    domain.Role(strings.TrimSpace(role)),
+		})
+	}
+	return mapping, nil
+}
+
+type groupRole struct {
+	dn   *ldap.DN
+	role domain.Role
+}
+
+type ldapDirectory struct {
+	config LDAPConfig
+	groups []groupRole
+	host   string
+}
+
+// NewLDAP checks the configuration and fills in the OpenLDAP defaults for
+// whatever is left out: accounts are found by uid, and groups are searched
+// for under BaseDN. No connection is made until the first Authenticate.
+func NewLDAP(config LDAPConfig) (Directory, error) {
+	if config.URL == "" || config.BaseDN == "" {
+		return nil, fmt.Errorf("LDAP needs a URL and a base DN")
+	}
+	address, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	if config.DefaultRole != "" && !config.DefaultRole.Valid() {
+		return nil, fmt.Errorf("invalid default role %q", config.DefaultRole)
+	}
+
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid=%s)"
+	}
+	if config.GroupBaseDN == "" {
+		config.GroupBaseDN = config.BaseDN
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.FirstNameAttribute == "" {
+		config.FirstNameAttribute = "givenName"
+	}
+	if config.LastNameAttribute == "" {
+		config.LastNameAttribute = "sn"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	d := &ldapDirectory{config: config, host: address.Hostname()}
+	for _, mapping := range config.GroupRoles {
+		if !mapping.Role.Valid() {
+			return nil, fmt.Errorf("invalid role %q for group %s", mapping.Role, mapping.GroupDN)
+		}
+		dn, err := ldap.ParseDN(mapping.GroupDN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group DN %q: %w", mapping.GroupDN, err)
+		}
+		d.groups = append(d.groups, groupRole{dn: dn, role: mapping.Role})
+	}
+	return d, nil
+}
+
+func (d *ldapDirectory) Authenticate(username, password string) (*Entry, error) {
+	// Servers take a bind with an empty password as an anonymous bind and
+	// report success.
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := d.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		d.timeLimit(),
+		false,
+		strings.ReplaceAll(d.config.UserFilter, "%s", ldap.EscapeFilter(username)),
+		[]string{
+			d.config.UsernameAttribute,
+			d.config.EmailAttribute,
+			d.config.FirstNameAttribute,
+			d.config.LastNameAttribute,
+			"memberOf",
+		},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("LDAP user search failed: %w", err)
+	}
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		return nil, ErrUserNotFound
+	case len(result.Entries) > 1:
+		return nil, fmt.Errorf("LDAP user filter matches more than one entry for %q", username)
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("LDAP bind failed: %w", err)
+	}
+
+	entry := &Entry{
+		DN:        found.DN,
+		Username:  found.GetAttributeValue(d.config.UsernameAttribute),
+		Email:     found.GetAttributeValue(d.config.EmailAttribute),
+		FirstName: found.GetAttributeValue(d.config.FirstNameAttribute),
+		LastName:  found.GetAttributeValue(d.config.LastNameAttribute),
+		Groups:    found.GetAttributeValues("memberOf"),
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+
+	if d.config.GroupFilter != "" {
+		// The user may not be allowed to read groups, so search as the
+		// service account again.
+		if err := d.bindService(conn); err != nil {
+			return nil, err
+		}
+		groups, err := conn.Search(ldap.NewSearchRequest(
+			d.config.GroupBaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0,
+			d.timeLimit(),
+			false,
+			strings.ReplaceAll(d.config.GroupFilter, "%s", ldap.EscapeFilter(found.DN)),
+			[]string{"dn"},
+			nil,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("LDAP group search failed: %w", err)
+		}
+		for _, group := range groups.Entries {
+			entry.Groups = append(entry.Groups, group.DN)
+		}
+	}
+
+	entry.Roles = d.roles(entry.Groups)
+	return entry, nil
+}
+
+func (d *ldapDirectory) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(d.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: d.config.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP connection failed: %w", err)
+	}
+	conn.SetTimeout(d.config.Timeout)
+
+	if d.config.StartTLS {
+		if err := conn.StartTLS(&tls.Config{ServerName: d.host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (d *ldapDirectory) bindService(conn *ldap.Conn) error {
+	var err error
+	if d.config.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(d.config.BindDN, d.config.BindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("LDAP service bind failed: %w", err)
+	}
+	return nil
+}
+
+func (d *ldapDirectory) timeLimit() int {
+	return int(d.config.Timeout / time.Second)
+}
+
+// roles maps group DNs to roles. DNs are compared attribute by attribute,
+// ignoring case and spacing, as directories do.
+func (d *ldapDirectory) roles(groups []string) []domain.Role {
+	var roles []domain.Role
+	seen := make(map[domain.Role]bool)
+	for _, group := range groups {
+		dn, err := ldap.ParseDN(group)
+		if err != nil {
+			continue
+		}
+		for _, mapping := range d.groups {
+			if !seen[mapping.role] && mapping.dn.EqualFold(dn) {
+				seen[mapping.role] = true
+				roles = append(roles, mapping.role)
+			}
+		}
+	}
+	if len(roles) == 0 && d.config.DefaultRole != "" {
+		roles = append(roles, d.config.DefaultRole)
+	}
+	return roles
+}
@@ -0,0 +1,274 @@
+package directory
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+const (
+	serviceDN       = "cn=reader,dc=example,dc=org"
+	servicePassword = "reader-secret"
+)
+
+// testServer is an in-process LDAP server that answers just enough of the
+// protocol for ldapDirectory: simple binds, searches looked up by their
+// filter, and unbinds.
+type testServer struct {
+	listener  net.Listener
+	passwords map[string]string
+	// results holds the entries a search returns, keyed by its filter as
+	// go-ldap prints it.
+	results map[string][]*ldap.Entry
+
+	mu sync.Mutex
+	// searches records each search filter with the DN bound when it ran.
+	searches []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		listener:  listener,
+		passwords: map[string]string{serviceDN: servicePassword},
+		results:   map[string][]*ldap.Entry{},
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultSuccess)
+			if dn != "" || password != "" {
+				if want, ok := s.passwords[dn]; !ok || want != password {
+					code = ldap.LDAPResultInvalidCredentials
+				}
+			}
+			if code == ldap.LDAPResultSuccess {
+				bound = dn
+			}
+			s.reply(conn, id, ldap.ApplicationBindResponse, code)
+
+		case ldap.ApplicationSearchRequest:
+			filter, err := ldap.DecompileFilter(request.Children[6])
+			if err != nil {
+				s.reply(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)
+				continue
+			}
+			s.mu.Lock()
+			s.searches = append(s.searches, bound+" "+filter)
+			s.mu.Unlock()
+			for _, entry := range s.results[filter] {
+				s.send(conn, id, searchResultEntry(entry))
+			}
+			s.reply(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+
+		default:
+			return
+		}
+	}
+}
+
+func (s *testServer) send(conn net.Conn, id interface{}, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	packet.AppendChild(op)
+	conn.Write(packet.Bytes())
+}
+
+func (s *testServer) reply(conn net.Conn, id interface{}, tag ber.Tag, code uint16) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	s.send(conn, id, op)
+}
+
+func searchResultEntry(entry *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+	attributes := ber.NewSequence("Attributes")
+	for _, attribute := range entry.Attributes {
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute.Name, "Type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range attribute.Values {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attr.AppendChild(values)
+		attributes.AppendChild(attr)
+	}
+	op.AppendChild(attributes)
+	return op
+}
+
+func newTestDirectory(t *testing.T, server *testServer, config LDAPConfig) Directory {
+	t.Helper()
+
+	config.URL = server.URL()
+	config.BindDN = serviceDN
+	config.BindPassword = servicePassword
+	config.BaseDN = "dc=example,dc=org"
+	config.GroupRoles = []GroupRole{
+		{GroupDN: "cn=doctors,ou=groups,dc=example,dc=org", Role: domain.RoleDoctor},
+		{GroupDN: "cn=managers,ou=groups,dc=example,dc=org", Role: domain.RoleManager},
+	}
+	dir, err := NewLDAP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	server := newTestServer(t)
+	const userDN = "uid=jdoe,ou=people,dc=example,dc=org"
+	server.passwords[userDN] = "secret"
+	server.results["(uid=jdoe)"] = []*ldap.Entry{ldap.NewEntry(userDN, map[string][]string{
+		"uid":       {"jdoe"},
+		"mail":      {"jdoe@example.org"},
+		"givenName": {"John"},
+		"sn":        {"Doe"},
+		// Group DNs match whatever their case and spacing.
+		"memberOf": {"CN=Doctors, OU=Groups, DC=example, DC=org", "cn=nurses,ou=groups,dc=example,dc=org"},
+	})}
+	server.results["(member="+userDN+")"] = []*ldap.Entry{
+		ldap.NewEntry("cn=managers,ou=groups,dc=example,dc=org", nil),
+	}
+	dir := newTestDirectory(t, server, LDAPConfig{GroupFilter: "(member=%s)"})
+
+	entry, err := dir.Authenticate("jdoe", "secret")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	want := &Entry{
+		DN:        userDN,
+		Username:  "jdoe",
+		Email:     "jdoe@example.org",
+		FirstName: "John",
+		LastName:  "Doe",
+		Groups: []string{
+			"CN=Doctors, OU=Groups, DC=example, DC=org",
+			"cn=nurses,ou=groups,dc=example,dc=org",
+			"cn=managers,ou=groups,dc=example,dc=org",
+		},
+		Roles: []domain.Role{domain.RoleDoctor, domain.RoleManager},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v, want %+v", entry, want)
+	}
+
+	// Groups are searched as the service account, not as the user.
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if got := server.searches[len(server.searches)-1]; got != serviceDN+" (member="+userDN+")" {
+		t.Errorf("group search ran as %q", got)
+	}
+}
+
+func TestLDAPAuthenticateFailures(t *testing.T) {
+	server := newTestServer(t)
+	const userDN = "uid=jdoe,ou=people,dc=example,dc=org"
+	server.passwords[userDN] = "secret"
+	server.results["(uid=jdoe)"] = []*ldap.Entry{ldap.NewEntry(userDN, nil)}
+	server.results["(uid=twin)"] = []*ldap.Entry{
+		ldap.NewEntry("uid=twin,ou=people,dc=example,dc=org", nil),
+		ldap.NewEntry("uid=twin,ou=staff,dc=example,dc=org", nil),
+	}
+	dir := newTestDirectory(t, server, LDAPConfig{})
+
+	for _, tc := range []struct {
+		name               string
+		username, password string
+		want               error
+	}{
+		{"wrong password", "jdoe", "guess", ErrInvalidCredentials},
+		{"empty password", "jdoe", "", ErrInvalidCredentials},
+		{"unknown user", "nobody", "secret", ErrUserNotFound},
+		// The username is escaped, so it cannot widen the filter.
+		{"filter injection", "*)(uid=*", "secret", ErrUserNotFound},
+	} {
+		if _, err := dir.Authenticate(tc.username, tc.password); err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	_, err := dir.Authenticate("twin", "secret")
+	if err == nil || errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("ambiguous username: got %v, want a directory error", err)
+	}
+}
+
+func TestLDAPAuthenticateDefaultRole(t *testing.T) {
+	server := newTestServer(t)
+	const userDN = "uid=desk,ou=people,dc=example,dc=org"
+	server.passwords[userDN] = "secret"
+	server.results["(uid=desk)"] = []*ldap.Entry{ldap.NewEntry(userDN, nil)}
+
+	entry, err := newTestDirectory(t, server, LDAPConfig{}).Authenticate("desk", "secret")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if len(entry.Roles) != 0 {
+		t.Errorf("roles without a default = %v, want none", entry.Roles)
+	}
+
+	entry, err = newTestDirectory(t, server, LDAPConfig{DefaultRole: domain.RoleUser}).Authenticate("desk", "secret")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if want := []domain.Role{domain.RoleUser}; !reflect.DeepEqual(entry.Roles, want) {
+		t.Errorf("roles = %v, want %v", entry.Roles, want)
+	}
+}
+
+func TestLDAPAuthenticateUnreachable(t *testing.T) {
+	server := newTestServer(t)
+	dir := newTestDirectory(t, server, LDAPConfig{})
+	server.listener.Close()
+
+	_, err := dir.Authenticate("jdoe", "secret")
+	if err == nil || errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v, want a directory error", err)
+	}
+}
@@ -0,0 +1,84 @@
+package domain
+
+import "time"
+
+// Audit actions.
+const (
+	AuditSignUp                 = "auth.sign_up"
+	AuditSignIn                 = "auth.sign_in"
+	AuditSignInFailed           = "auth.sign_in_failed"
+	AuditSignOut                = "auth.sign_out"
+	AuditSessionRevoked         = "auth.session_revoked"
+	AuditImpersonationStarted   = "auth.impersonation_started"
+	AuditImpersonatedRequest    = "auth.impersonated_request"
+	AuditPasswordChanged        = "auth.password_changed"
+	AuditPasswordReset          = "auth.password_reset"
+	AuditMFAEnabled             = "auth.mfa_enabled"
+	AuditMFADisabled            = "auth.mfa_disabled"
+	AuditRecoveryCodesReset     = "auth.recovery_codes_regenerated"
+	AuditAccountVerified        = "auth.account_verified"
+	AuditAccountCreated         = "account.created"
+	AuditAccountApproved        = "account.approved"
+	AuditAccountUpdated         = "account.updated"
+	AuditAccountDisabled        = "account.disabled"
+	AuditAccountEnabled         = "account.enabled"
+	AuditAccountDeleted         = "account.deleted"
+	AuditAccountRestored        = "account.restored"
+	AuditAccountPurged          = "account.purged"
+	AuditAccountExpired         = "account.expired"
+	AuditRoleGranted            = "account.role_granted"
+	AuditRoleRevoked            = "account.role_revoked"
+	AuditTokensRevoked          = "account.tokens_revoked"
+	AuditAccountUnlocked        = "account.unlocked"
+	AuditPasswordChangeRequired = "account.password_change_required"
+	AuditMFAReset               = "account.mfa_reset"
+	AuditMFAPolicyUpdated       = "mfa.policy_updated"
+	AuditPatientProfileUpdated  = "patient.profile_updated"
+	AuditPatientProfileDeleted  = "patient.profile_deleted"
+	AuditDoctorProfileUpdated   = "doctor.profile_updated"
+	AuditDoctorProfileDeleted   = "doctor.profile_deleted"
+)
+
+// Actor is whoever performs an audited action: a signed-in user, a service
+// client, or an anonymous caller identified only by address. SessionID is
+// the session a signed-in user acted from. ImpersonatorID is the admin
+// acting as UserID through an impersonation token.
+type Actor struct {
+	UserID         uint
+	SessionID      uint
+	ClientID       string
+	ImpersonatorID uint
+	IP             string
+	UserAgent      string
+}
+
+// AuditEvent is one entry of the append-only security log. TargetID is the
+// account the action was applied to, if any.
+type AuditEvent struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+	ActorID       uint      `gorm:"index" json:"actor_id,omitempty"`
+	ActorClientID string    `json:"actor_client_id,omitempty"`
+	// ImpersonatorID is the admin behind ActorID when the action was taken
+	// with an impersonation token.
+	ImpersonatorID uint   `gorm:"index" json:"impersonator_id,omitempty"`
+	TargetID       uint   `gorm:"index" json:"target_id,omitempty"`
+	Action         string `gorm:"index;not null" json:"action"`
+	Details        string `json:"details,omitempty"`
+	IP             string `json:"ip"`
+	UserAgent      string `json:"user_agent"`
+}
+
+type AuditFilter struct {
+	ActorID  uint
+	TargetID uint
+	Action   string
+	Since    *time.Time
+	Until    *time.Time
+}
+
+// As attributes a request from this client to the given user, or to nobody
+// when userID is zero.
+func (c ClientInfo) As(userID uint) Actor {
+	return Actor{UserID: userID, IP: c.IP, UserAgent: c.UserAgent}
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// DoctorProfile is the professional side of a doctor account. HospitalIDs
+// refer to hospitals in hospital-service.
+type DoctorProfile struct {
+	ID              uint      `gorm:"primarykey" json:"-"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	UserID          uint      `gorm:"uniqueIndex;not null" json:"user_id"`
+	Specializations []string  `gorm:"type:text[];not null" json:"specializations"`
+	LicenseNumber   string    `json:"license_number"`
+	HospitalIDs     []int64   `gorm:"type:bigint[];not null" json:"hospital_ids"`
+	Bio             string    `json:"bio"`
+}
+
+// Doctor is a doctor account as shown in the directory, which every
+// signed-in user can read, so it leaves out the account's private fields.
+// Profile is nil until one has been filled in.
+type Doctor struct {
+	ID        uint           `json:"id"`
+	FirstName string         `json:"first_name"`
+	LastName  string         `json:"last_name"`
+	Roles     []Role         `json:"roles"`
+	Profile   *DoctorProfile `json:"profile"`
+}
+
+// DoctorFilter narrows the doctor directory. Name and Specialization match
+// substrings, case-insensitively.
+type DoctorFilter struct {
+	Name           string
+	Specialization string
+	HospitalID     int64
+}
+
+type DoctorPage struct {
+	Items []Doctor `json:"items"`
+	Total int64    `json:"total"`
+}
+
+// DoctorProfileRequest replaces a doctor's whole profile.
+type DoctorProfileRequest struct {
+	Specializations []string `json:"specializations"`
+	LicenseNumber   string   `json:"license_number"`
+	HospitalIDs     []int64  `json:"hospital_ids" binding:"dive,gt=0"`
+	Bio             string   `json:"bio"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// LoginAttempt records a failed sign-in for throttling and for the security
+// team to review. Cleared is set once a successful sign-in or an admin
+// unlock resets the counter; cleared rows are kept as history.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	Username  string    `gorm:"index;not null" json:"username"`
+	IP        string    `gorm:"index" json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	Cleared   bool      `gorm:"not null;default:false" json:"cleared"`
+}
+
+const (
+	LoginFailureUnknownUser     = "unknown_user"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidMFACode  = "invalid_mfa_code"
+)
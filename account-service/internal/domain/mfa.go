@@ -0,0 +1,91 @@
+package domain
+
+import "time"
+
+// MFACredential is a user's TOTP secret. It only protects sign-in once it
+// has been confirmed with a valid code.
+type MFACredential struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uint   `gorm:"uniqueIndex;not null"`
+	Secret       string `gorm:"not null"`
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+}
+
+func (c *MFACredential) Confirmed() bool {
+	return c != nil && c.ConfirmedAt != nil
+}
+
+// RecoveryCode is a single-use fallback for a lost authenticator.
+type RecoveryCode struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// MFAChallenge is issued by SignIn after the password check when the
+// account needs a second factor. Enrollment is set when the user's role
+// requires MFA but they have not set it up yet.
+type MFAChallenge struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	UserID     uint      `gorm:"index;not null"`
+	TokenHash  string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	Attempts   int
+	Enrollment bool
+	UsedAt     *time.Time
+}
+
+// MFARequirement marks a role whose members must use MFA.
+type MFARequirement struct {
+	Role      Role `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// SignInResponse carries either the tokens or, when the account needs a
+// second factor, a challenge to complete through VerifyMFA.
+type SignInResponse struct {
+	*TokenResponse
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	ChallengeToken        string `json:"challenge_token,omitempty"`
+	ExpiresIn             int64  `json:"expires_in,omitempty"`
+}
+
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// MFAVerifyResponse also returns the recovery codes when the verification
+// completed an enrollment.
+type MFAVerifyResponse struct {
+	*TokenResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type MFAChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAPolicy struct {
+	Roles []Role `json:"roles"`
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// PatientProfile holds what the hospital needs to know about the person
+// behind a patient account. It is keyed by the account's user ID, which is
+// also the patient ID used by timetable-service and document-service.
+type PatientProfile struct {
+	ID          uint      `gorm:"primarykey" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UserID      uint      `gorm:"uniqueIndex;not null" json:"user_id"`
+	DateOfBirth *Date     `json:"date_of_birth"`
+	Gender      string    `json:"gender"`
+	Phone       string    `json:"phone"`
+	Address     string    `json:"address"`
+
+	EmergencyContactName     string `json:"emergency_contact_name"`
+	EmergencyContactPhone    string `json:"emergency_contact_phone"`
+	EmergencyContactRelation string `json:"emergency_contact_relation"`
+
+	InsuranceProvider     string `json:"insurance_provider"`
+	InsurancePolicyNumber string `json:"insurance_policy_number"`
+	InsuranceValidUntil   *Date  `json:"insurance_valid_until"`
+
+	// ConsentTreatment and ConsentDataSharing record what the patient has
+	// agreed to; ConsentUpdatedAt is when either last changed.
+	ConsentTreatment   bool       `gorm:"not null;default:false" json:"consent_treatment"`
+	ConsentDataSharing bool       `gorm:"not null;default:false" json:"consent_data_sharing"`
+	ConsentUpdatedAt   *time.Time `json:"consent_updated_at"`
+}
+
+// PatientProfileRequest replaces a patient's whole profile.
+type PatientProfileRequest struct {
+	DateOfBirth *Date  `json:"date_of_birth"`
+	Gender      string `json:"gender" binding:"omitempty,oneof=male female other"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+
+	EmergencyContactName     string `json:"emergency_contact_name"`
+	EmergencyContactPhone    string `json:"emergency_contact_phone"`
+	EmergencyContactRelation string `json:"emergency_contact_relation"`
+
+	InsuranceProvider     string `json:"insurance_provider"`
+	InsurancePolicyNumber string `json:"insurance_policy_number"`
+	InsuranceValidUntil   *Date  `json:"insurance_valid_until"`
+
+	ConsentTreatment   bool `json:"consent_treatment"`
+	ConsentDataSharing bool `json:"consent_data_sharing"`
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// RegistrationMode decides what a self-registered account has to go
+// through before it can sign in.
+type RegistrationMode string
+
+const (
+	// RegistrationOpen activates accounts as soon as they sign up.
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationVerify sends a one-time code to the email address given
+	// at sign-up and activates the account once it is entered.
+	RegistrationVerify RegistrationMode = "verify"
+	// RegistrationApproval keeps accounts pending until an admin approves
+	// them.
+	RegistrationApproval RegistrationMode = "approval"
+)
+
+func (m RegistrationMode) Valid() bool {
+	switch m {
+	case RegistrationOpen, RegistrationVerify, RegistrationApproval:
+		return true
+	}
+	return false
+}
+
+// UserStatus tells whether an account may sign in. Only active accounts
+// can; the pending ones are waiting for their owner or an admin, and
+// disabled ones have been switched off by an admin or a provisioning client.
+type UserStatus string
+
+const (
+	UserActive              UserStatus = "active"
+	UserPendingVerification UserStatus = "pending_verification"
+	UserPendingApproval     UserStatus = "pending_approval"
+	UserDisabled            UserStatus = "disabled"
+)
+
+func (s UserStatus) Valid() bool {
+	switch s {
+	case UserActive, UserPendingVerification, UserPendingApproval, UserDisabled:
+		return true
+	}
+	return false
+}
+
+// VerificationCode is a one-time code sent to confirm the email address of
+// a new account. Only its hash is stored.
+type VerificationCode struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint      `gorm:"index;not null"`
+	CodeHash  string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	UsedAt    *time.Time
+}
+
+type SignUpResponse struct {
+	Status UserStatus `json:"status"`
+}
+
+type VerifyAccountRequest struct {
+	Username string `json:"username" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Username string `json:"username" binding:"required"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// TokenRevocation cuts off access tokens before they expire. An entry with a
+// JTI revokes that single token, an entry with a SessionID every token of
+// that session and an entry with a ClientID every token of that service
+// client; an entry with none of them revokes every token of the user issued
+// before it was created. Its CreatedAt is whole seconds like a token's iat,
+// so a token issued in the same second, such as the one handed out right
+// after a password change, stays valid. Entries are only kept
+// until every token they cover has expired on its own.
+type TokenRevocation struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	JTI       string    `gorm:"index"`
+	ClientID  string    `gorm:"index;not null;default:''"`
+	UserID    uint      `gorm:"index"`
+	SessionID uint      `gorm:"index;not null;default:0"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+type RevokedUser struct {
+	UserID    uint      `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// RevocationList is the published form of the active revocations that
+// downstream services cache locally.
+type RevocationList struct {
+	JTIs     []string      `json:"jtis"`
+	Sessions []uint        `json:"sessions"`
+	Clients  []string      `json:"clients"`
+	Users    []RevokedUser `json:"users"`
+}
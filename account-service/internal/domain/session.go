@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/auth"
+)
+
+// Session is a refresh token family issued to one device. Every refresh
+// rotates the token inside the session; revoking the session retires the
+// whole family.
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	DeviceID   string     `gorm:"index" json:"device_id"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Current marks the session the listing was requested from.
+	Current bool `gorm:"-" json:"current"`
+}
+
+type RefreshToken struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	SessionID uint      `gorm:"index;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RotatedAt *time.Time
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	DeviceID  string
+	IP        string
+	UserAgent string
+}
+
+// IntrospectionResponse follows RFC 7662. Inactive tokens only carry
+// Active, so nothing about them leaks to the caller.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Roles     []Role `json:"roles,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Sid       uint   `json:"sid,omitempty"`
+	// Act names the admin using an impersonation token.
+	Act *auth.ActorClaim `json:"act,omitempty"`
+}
+
+// ImpersonateRequest asks for a token to act as another user. The reason
+// goes into the audit log.
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ImpersonationResponse carries a short-lived access token with no refresh
+// token; impersonating for longer means asking again.
+type ImpersonationResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// PasswordResetToken lets a user set a new password without signing in.
+// Only its hash is stored and it can be used once.
+type PasswordResetToken struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// PasswordResetAttempt records that a reset was asked for from an address,
+// whether or not the account exists, so the requests can be rate limited.
+type PasswordResetAttempt struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	IP        string    `gorm:"index;not null"`
+}
+
+// PasswordResetRequest identifies the account by username or email.
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PasswordHistory keeps earlier password hashes so they cannot be reused.
+type PasswordHistory struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UserID       uint   `gorm:"index;not null"`
+	PasswordHash string `gorm:"not null"`
+}
+
+// ChangePasswordRequest lets a user whose password must be changed sign in
+// with a new one.
+type ChangePasswordRequest struct {
+	Username        string `json:"username" binding:"required"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Password        string `json:"password" binding:"required"`
+}
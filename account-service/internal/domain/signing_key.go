@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// SigningKey is a persisted JWT signing key. Keys are shared by every
+// account-service replica through the database.
+type SigningKey struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	KID        string    `gorm:"uniqueIndex;not null"`
+	PrivateKey string    `gorm:"not null"`
+	RetiredAt  *time.Time
+}
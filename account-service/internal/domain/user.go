@@ -3,32 +3,65 @@ package domain
 import (
 	"time"
 
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	"gorm.io/gorm"
 )
 
-type Role string
+// Role is shared with the token claims so every service reads the same
+// values account-service writes.
+type Role = auth.Role
 
 const (
-	RoleAdmin   Role = "Admin"
-	RoleManager Role = "Manager"
-	RoleDoctor  Role = "Doctor"
-	RoleUser    Role = "User"
+	RoleAdmin   = auth.RoleAdmin
+	RoleManager = auth.RoleManager
+	RoleDoctor  = auth.RoleDoctor
+	RoleUser    = auth.RoleUser
+)
+
+// UserSource tells which authenticator holds an account's credentials.
+// Directory accounts are created at their first sign-in and their password
+// lives in the directory, not here.
+type UserSource string
+
+const (
+	UserSourceLocal UserSource = "local"
+	UserSourceLDAP  UserSource = "ldap"
 )
 
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Username  string         `gorm:"uniqueIndex;not null" json:"username"`
-	Password  string         `gorm:"not null" json:"-"`
-	FirstName string         `gorm:"not null" json:"first_name"`
-	LastName  string         `gorm:"not null" json:"last_name"`
-	Roles     []Role         `gorm:"type:text[];not null" json:"roles"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	// Usernames are unique among live accounts only, so a deleted user's
+	// name can be taken again.
+	Username  string `gorm:"uniqueIndex:idx_users_username_active,where:deleted_at IS NULL;not null" json:"username"`
+	Email     string `gorm:"index" json:"email,omitempty"`
+	Password  string `gorm:"not null" json:"-"`
+	FirstName string `gorm:"not null" json:"first_name"`
+	LastName  string `gorm:"not null" json:"last_name"`
+	Roles     []Role `gorm:"type:text[];not null" json:"roles"`
+	// MustChangePassword makes SignIn refuse the account until a new
+	// password is set through ChangePassword.
+	MustChangePassword bool `gorm:"not null;default:false" json:"must_change_password"`
+	// Status keeps self-registered accounts from signing in until they are
+	// verified or approved.
+	Status UserStatus `gorm:"index;not null;default:active" json:"status"`
+	Source UserSource `gorm:"not null;default:local" json:"source"`
+}
+
+func (u *User) HasRole(role Role) bool {
+	for _, have := range u.Roles {
+		if have == role {
+			return true
+		}
+	}
+	return false
 }
 
 type SignUpRequest struct {
 	Username  string `json:"username" binding:"required"`
+	Email     string `json:"email" binding:"omitempty,email"`
 	Password  string `json:"password" binding:"required"`
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
@@ -49,15 +82,60 @@ type RefreshTokenRequest struct {
 }
 
 type UpdateUserRequest struct {
+	Email     string `json:"email" binding:"omitempty,email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+}
+
+// AdminUpdateUserRequest changes any field of an account. Empty fields
+// are left as they are; Roles, when present, replaces the whole set.
+type AdminUpdateUserRequest struct {
+	Username  string `json:"username"`
+	Email     string `json:"email" binding:"omitempty,email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Password  string `json:"password"`
+	Roles     []Role `json:"roles"`
+}
+
+// UserFilter narrows an account listing. Search matches the username or
+// the full name; Sort is a column name, prefixed with "-" for descending
+// order. Status defaults to active accounts.
+type UserFilter struct {
+	Search         string
+	Role           Role
+	Status         UserStatus
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	Sort           string
+	IncludeDeleted bool
+}
+
+// UserPage is one page of a listing together with the number of accounts
+// that match in total.
+type UserPage struct {
+	Items []User `json:"items"`
+	Total int64  `json:"total"`
+}
+
+// RestoreUserRequest brings a deleted account back. Username renames it,
+// which is needed when its old name has been taken in the meantime.
+type RestoreUserRequest struct {
+	Username string `json:"username"`
+}
+
+type RoleRequest struct {
+	Role Role `json:"role" binding:"required"`
 }
 
 type CreateUserRequest struct {
 	Username  string `json:"username" binding:"required"`
+	Email     string `json:"email" binding:"omitempty,email"`
 	Password  string `json:"password" binding:"required"`
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
 	Roles     []Role `json:"roles" binding:"required"`
+
+	MustChangePassword bool `json:"must_change_password"`
 }
@@ -0,0 +1,45 @@
+package domain
+
+// ImportUserRow is one account of a bulk import. Leaving Password empty
+// has a temporary one generated, which must be changed at first sign-in.
+type ImportUserRow struct {
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Roles     []Role `json:"roles"`
+	Password  string `json:"password,omitempty"`
+}
+
+// ImportOptions controls a bulk import. A dry run only validates the rows.
+// With SendPasswords, generated passwords are mailed to each account
+// instead of being returned.
+type ImportOptions struct {
+	DryRun        bool
+	SendPasswords bool
+}
+
+// ImportRowError lists the problems of one row. Row counts data rows from
+// 1, not including a CSV header.
+type ImportRowError struct {
+	Row      int                 `json:"row"`
+	Username string              `json:"username,omitempty"`
+	Fields   map[string][]string `json:"fields"`
+}
+
+// GeneratedPassword is a temporary password created during an import. It is
+// not stored in plain text anywhere, so this is the only time it is shown.
+type GeneratedPassword struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ImportResult reports what an import did. If any row has errors nothing
+// is created.
+type ImportResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Rows      int                 `json:"rows"`
+	Created   int                 `json:"created"`
+	Errors    []ImportRowError    `json:"errors,omitempty"`
+	Passwords []GeneratedPassword `json:"passwords,omitempty"`
+}
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type logNotifier struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewLogNotifier writes messages to out instead of sending them, for local
+// development where no mail server is available.
+func NewLogNotifier(out io.Writer) Notifier {
+	return &logNotifier{out: out}
+}
+
+func (n *logNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.out, "[%s] to=%s subject=%q\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
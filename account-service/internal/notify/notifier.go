@@ -0,0 +1,15 @@
+// Package notify delivers messages such as password reset links to users.
+package notify
+
+import "context"
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+const auditExportBatchSize = 500
+
+// AuditRepository only appends and reads; the table also rejects updates
+// and deletes at the database level, see EnsureAuditAppendOnly.
+type AuditRepository interface {
+	Create(event *domain.AuditEvent) error
+	List(filter domain.AuditFilter, offset, limit int) ([]domain.AuditEvent, error)
+	Each(filter domain.AuditFilter, fn func([]domain.AuditEvent) error) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// EnsureAuditAppendOnly installs a trigger that makes the audit table
+// refuse UPDATE and DELETE, so entries cannot be rewritten even with
+// direct database access from the service account.
+func EnsureAuditAppendOnly(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+			`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+			`CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *auditRepository) Create(event *domain.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *auditRepository) List(filter domain.AuditFilter, offset, limit int) ([]domain.AuditEvent, error) {
+	var events []domain.AuditEvent
+	err := r.filtered(filter).
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Each walks every matching event in chronological order, in batches.
+func (r *auditRepository) Each(filter domain.AuditFilter, fn func([]domain.AuditEvent) error) error {
+	var batch []domain.AuditEvent
+	return r.filtered(filter).
+		Order("id").
+		FindInBatches(&batch, auditExportBatchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *auditRepository) filtered(filter domain.AuditFilter) *gorm.DB {
+	query := r.db.Model(&domain.AuditEvent{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	return query
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ClientRepository interface {
+	Create(client *domain.ServiceClient) error
+	GetByID(id uint) (*domain.ServiceClient, error)
+	GetByClientID(clientID string) (*domain.ServiceClient, error)
+	Update(client *domain.ServiceClient) error
+	Delete(id uint) error
+	List() ([]domain.ServiceClient, error)
+}
+
+type clientRepository struct {
+	db *gorm.DB
+}
+
+func NewClientRepository(db *gorm.DB) ClientRepository {
+	return &clientRepository{db: db}
+}
+
+func (r *clientRepository) Create(client *domain.ServiceClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *clientRepository) GetByID(id uint) (*domain.ServiceClient, error) {
+	var client domain.ServiceClient
+	if err := r.db.First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *clientRepository) GetByClientID(clientID string) (*domain.ServiceClient, error) {
+	var client domain.ServiceClient
+	if err := r.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *clientRepository) Update(client *domain.ServiceClient) error {
+	return r.db.Save(client).Error
+}
+
+func (r *clientRepository) Delete(id uint) error {
+	return r.db.Delete(&domain.ServiceClient{}, id).Error
+}
+
+func (r *clientRepository) List() ([]domain.ServiceClient, error) {
+	var clients []domain.ServiceClient
+	if err := r.db.Order("id").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
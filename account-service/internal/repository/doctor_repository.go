@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DoctorRepository interface {
+	List(filter domain.DoctorFilter, offset, limit int) ([]domain.User, int64, error)
+	GetByID(id uint) (*domain.User, error)
+	GetProfile(userID uint) (*domain.DoctorProfile, error)
+	ListProfiles(userIDs []uint) ([]domain.DoctorProfile, error)
+	SaveProfile(profile *domain.DoctorProfile) error
+	DeleteProfile(userID uint) (bool, error)
+}
+
+type doctorRepository struct {
+	db *gorm.DB
+}
+
+func NewDoctorRepository(db *gorm.DB) DoctorRepository {
+	return &doctorRepository{db: db}
+}
+
+// List and GetByID only find active accounts, so disabled and pending
+// doctors stay out of the directory.
+func (r *doctorRepository) List(filter domain.DoctorFilter, offset, limit int) ([]domain.User, int64, error) {
+	query := r.db.Model(&domain.User{}).
+		Joins("LEFT JOIN doctor_profiles ON doctor_profiles.user_id = users.id").
+		Where("? = ANY(users.roles) AND users.status = ?", domain.RoleDoctor, domain.UserActive)
+	if filter.Name != "" {
+		query = query.Where("(users.first_name || ' ' || users.last_name) ILIKE ?", "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.Specialization != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM unnest(doctor_profiles.specializations) AS specialization WHERE specialization ILIKE ?)",
+			"%"+escapeLike(filter.Specialization)+"%")
+	}
+	if filter.HospitalID != 0 {
+		query = query.Where("? = ANY(doctor_profiles.hospital_ids)", filter.HospitalID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []domain.User
+	if err := query.Select("users.*").Order("users.id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *doctorRepository) GetByID(id uint) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Where("? = ANY(users.roles) AND users.status = ?", domain.RoleDoctor, domain.UserActive).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *doctorRepository) GetProfile(userID uint) (*domain.DoctorProfile, error) {
+	var profile domain.DoctorProfile
+	if err := r.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *doctorRepository) ListProfiles(userIDs []uint) ([]domain.DoctorProfile, error) {
+	var profiles []domain.DoctorProfile
+	if len(userIDs) == 0 {
+		return profiles, nil
+	}
+	if err := r.db.Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (r *doctorRepository) SaveProfile(profile *domain.DoctorProfile) error {
+	return r.db.Save(profile).Error
+}
+
+// DeleteProfile reports false when the doctor had no profile.
+func (r *doctorRepository) DeleteProfile(userID uint) (bool, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&domain.DoctorProfile{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+// FailureStats summarises the failures counted against a username or IP.
+type FailureStats struct {
+	Count int64
+	Last  time.Time
+}
+
+type LoginAttemptRepository interface {
+	Create(attempt *domain.LoginAttempt) error
+	FailuresByUsername(username string, since time.Time) (*FailureStats, error)
+	FailuresByIP(ip string, since time.Time) (*FailureStats, error)
+	ClearFailures(username string) error
+	List(username, ip string, offset, limit int) ([]domain.LoginAttempt, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Create(attempt *domain.LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+func (r *loginAttemptRepository) FailuresByUsername(username string, since time.Time) (*FailureStats, error) {
+	return r.failures(r.db.Where("username = ?", username), since)
+}
+
+func (r *loginAttemptRepository) FailuresByIP(ip string, since time.Time) (*FailureStats, error) {
+	return r.failures(r.db.Where("ip = ?", ip), since)
+}
+
+func (r *loginAttemptRepository) failures(query *gorm.DB, since time.Time) (*FailureStats, error) {
+	var row struct {
+		Count int64
+		Last  *time.Time
+	}
+	err := query.Model(&domain.LoginAttempt{}).
+		Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where("cleared = ? AND created_at > ?", false, since).
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &FailureStats{Count: row.Count}
+	if row.Last != nil {
+		stats.Last = *row.Last
+	}
+	return stats, nil
+}
+
+func (r *loginAttemptRepository) ClearFailures(username string) error {
+	return r.db.Model(&domain.LoginAttempt{}).
+		Where("username = ? AND cleared = ?", username, false).
+		Update("cleared", true).Error
+}
+
+func (r *loginAttemptRepository) List(username, ip string, offset, limit int) ([]domain.LoginAttempt, error) {
+	var attempts []domain.LoginAttempt
+	query := r.db.Order("created_at DESC")
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if err := query.Offset(offset).Limit(limit).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type MFARepository interface {
+	GetCredential(userID uint) (*domain.MFACredential, error)
+	SaveCredential(credential *domain.MFACredential) error
+	// MarkStepUsed records a TOTP step as consumed. It reports false if that
+	// step or a later one was already used, so a code cannot be replayed.
+	MarkStepUsed(credentialID uint, step int64) (bool, error)
+	DeleteCredential(userID uint) error
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	UseRecoveryCode(userID uint, hash string) (bool, error)
+	CreateChallenge(challenge *domain.MFAChallenge) error
+	GetChallengeByHash(hash string) (*domain.MFAChallenge, error)
+	IncrementChallengeAttempts(id uint) error
+	MarkChallengeUsed(id uint) (bool, error)
+	ListRequiredRoles() ([]domain.Role, error)
+	SetRequiredRoles(roles []domain.Role) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetCredential(userID uint) (*domain.MFACredential, error) {
+	var credential domain.MFACredential
+	if err := r.db.Where("user_id = ?", userID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *mfaRepository) SaveCredential(credential *domain.MFACredential) error {
+	return r.db.Save(credential).Error
+}
+
+func (r *mfaRepository) MarkStepUsed(credentialID uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.MFACredential{}).
+		Where("id = ? AND last_used_step < ?", credentialID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) DeleteCredential(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.MFACredential{}).Error
+	})
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]domain.RecoveryCode, 0, len(hashes))
+		for _, hash := range hashes {
+			codes = append(codes, domain.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	result := r.db.Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) CreateChallenge(challenge *domain.MFAChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+func (r *mfaRepository) GetChallengeByHash(hash string) (*domain.MFAChallenge, error) {
+	var challenge domain.MFAChallenge
+	if err := r.db.Where("token_hash = ?", hash).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *mfaRepository) IncrementChallengeAttempts(id uint) error {
+	return r.db.Model(&domain.MFAChallenge{}).
+		Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+func (r *mfaRepository) MarkChallengeUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.MFAChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) ListRequiredRoles() ([]domain.Role, error) {
+	var requirements []domain.MFARequirement
+	if err := r.db.Order("role").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make([]domain.Role, 0, len(requirements))
+	for _, requirement := range requirements {
+		roles = append(roles, requirement.Role)
+	}
+	return roles, nil
+}
+
+func (r *mfaRepository) SetRequiredRoles(roles []domain.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&domain.MFARequirement{}).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Create(&domain.MFARequirement{Role: role}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository interface {
+	Create(entry *domain.PasswordHistory) error
+	ListRecent(userID uint, limit int) ([]domain.PasswordHistory, error)
+}
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) Create(entry *domain.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *passwordHistoryRepository) ListRecent(userID uint, limit int) ([]domain.PasswordHistory, error) {
+	var entries []domain.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
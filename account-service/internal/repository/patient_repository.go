@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type PatientRepository interface {
+	GetByUserID(userID uint) (*domain.PatientProfile, error)
+	Save(profile *domain.PatientProfile) error
+	DeleteByUserID(userID uint) (bool, error)
+}
+
+type patientRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientRepository(db *gorm.DB) PatientRepository {
+	return &patientRepository{db: db}
+}
+
+func (r *patientRepository) GetByUserID(userID uint) (*domain.PatientProfile, error) {
+	var profile domain.PatientProfile
+	if err := r.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *patientRepository) Save(profile *domain.PatientProfile) error {
+	return r.db.Save(profile).Error
+}
+
+// DeleteByUserID reports false when the user had no profile.
+func (r *patientRepository) DeleteByUserID(userID uint) (bool, error) {
+	result := r.db.Where("user_id = ?", userID).Delete(&domain.PatientProfile{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
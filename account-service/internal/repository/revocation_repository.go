@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type RevocationRepository interface {
+	Create(revocation *domain.TokenRevocation) error
+	IsRevoked(jti, clientID string, userID, sessionID uint, issuedAt time.Time) (bool, error)
+	ListActive() ([]domain.TokenRevocation, error)
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db: db}
+}
+
+func (r *revocationRepository) Create(revocation *domain.TokenRevocation) error {
+	return r.db.Create(revocation).Error
+}
+
+func (r *revocationRepository) IsRevoked(jti, clientID string, userID, sessionID uint, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.TokenRevocation{}).
+		Where("expires_at > ?", time.Now()).
+		Where("(jti <> '' AND jti = ?) OR (session_id <> 0 AND session_id = ?) OR "+
+			"(client_id <> '' AND client_id = ?) OR "+
+			"(jti = '' AND session_id = 0 AND client_id = '' AND user_id = ? AND created_at > ?)",
+			jti, sessionID, clientID, userID, issuedAt).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *revocationRepository) ListActive() ([]domain.TokenRevocation, error) {
+	var revocations []domain.TokenRevocation
+	if err := r.db.Where("expires_at > ?", time.Now()).Find(&revocations).Error; err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/scim"
+)
+
+type scimKind int
+
+const (
+	scimText scimKind = iota
+	scimNumber
+	scimTime
+	scimActive
+	scimRoles
+)
+
+// scimAttribute is where a SCIM User attribute is stored.
+type scimAttribute struct {
+	column string
+	kind   scimKind
+}
+
+// scimUserAttributes are the attributes a SCIM filter may test, by their
+// lower-case path.
+var scimUserAttributes = map[string]scimAttribute{
+	"id":                {"id", scimNumber},
+	"username":          {"username", scimText},
+	"name.givenname":    {"first_name", scimText},
+	"name.familyname":   {"last_name", scimText},
+	"name.formatted":    {"(first_name || ' ' || last_name)", scimText},
+	"displayname":       {"(first_name || ' ' || last_name)", scimText},
+	"emails":            {"email", scimText},
+	"emails.value":      {"email", scimText},
+	"active":            {"status", scimActive},
+	"meta.created":      {"created_at", scimTime},
+	"meta.lastmodified": {"updated_at", scimTime},
+	"roles":             {"roles", scimRoles},
+	"roles.value":       {"roles", scimRoles},
+	"groups":            {"roles", scimRoles},
+	"groups.value":      {"roles", scimRoles},
+	"groups.display":    {"roles", scimRoles},
+}
+
+var scimOrderOperators = map[string]string{
+	scim.OpEqual:          "=",
+	scim.OpNotEqual:       "<>",
+	scim.OpGreater:        ">",
+	scim.OpGreaterOrEqual: ">=",
+	scim.OpLess:           "<",
+	scim.OpLessOrEqual:    "<=",
+}
+
+// scimCondition turns a SCIM filter into a WHERE clause on the users table.
+// String comparisons ignore case, as SCIM asks for attributes that are not
+// case-exact.
+func scimCondition(expr scim.Expr) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case scim.Logical:
+		left, leftArgs, err := scimCondition(e.Left)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := scimCondition(e.Right)
+		if err != nil {
+			return "", nil, err
+		}
+		operator := " AND "
+		if e.Op == "or" {
+			operator = " OR "
+		}
+		return "(" + left + operator + right + ")", append(leftArgs, rightArgs...), nil
+	case scim.Not:
+		inner, args, err := scimCondition(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case scim.Comparison:
+		return scimComparison(e)
+	}
+	return "", nil, fmt.Errorf("%w: unexpected expression", scim.ErrInvalidFilter)
+}
+
+func scimComparison(c scim.Comparison) (string, []interface{}, error) {
+	attr, ok := scimUserAttributes[c.Attr]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: attribute %q cannot be filtered on", scim.ErrInvalidFilter, c.Attr)
+	}
+	unsupported := fmt.Errorf("%w: %s does not support %s", scim.ErrInvalidFilter, c.Attr, c.Op)
+	if c.Op == scim.OpPresent {
+		switch attr.kind {
+		case scimText:
+			return attr.column + " <> ''", nil, nil
+		case scimRoles:
+			return "cardinality(roles) > 0", nil, nil
+		}
+		return "TRUE", nil, nil
+	}
+
+	switch attr.kind {
+	case scimText:
+		value, ok := c.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %s takes a string", scim.ErrInvalidFilter, c.Attr)
+		}
+		switch c.Op {
+		case scim.OpContains:
+			return attr.column + " ILIKE ?", []interface{}{"%" + escapeLike(value) + "%"}, nil
+		case scim.OpStartsWith:
+			return attr.column + " ILIKE ?", []interface{}{escapeLike(value) + "%"}, nil
+		case scim.OpEndsWith:
+			return attr.column + " ILIKE ?", []interface{}{"%" + escapeLike(value)}, nil
+		}
+		return "LOWER(" + attr.column + ") " + scimOrderOperators[c.Op] + " LOWER(?)", []interface{}{value}, nil
+
+	case scimNumber:
+		operator, ok := scimOrderOperators[c.Op]
+		if !ok {
+			return "", nil, unsupported
+		}
+		var id uint64
+		var err error
+		switch value := c.Value.(type) {
+		case string:
+			id, err = strconv.ParseUint(value, 10, 32)
+		case float64:
+			id, err = strconv.ParseUint(strconv.FormatFloat(value, 'f', -1, 64), 10, 32)
+		default:
+			err = unsupported
+		}
+		if err != nil {
+			// No account can have an id that is not a number.
+			return "FALSE", nil, nil
+		}
+		return attr.column + " " + operator + " ?", []interface{}{id}, nil
+
+	case scimTime:
+		operator, ok := scimOrderOperators[c.Op]
+		if !ok {
+			return "", nil, unsupported
+		}
+		value, _ := c.Value.(string)
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s takes an RFC 3339 date-time", scim.ErrInvalidFilter, c.Attr)
+		}
+		return attr.column + " " + operator + " ?", []interface{}{at}, nil
+
+	case scimActive:
+		value, ok := c.Value.(bool)
+		if !ok || (c.Op != scim.OpEqual && c.Op != scim.OpNotEqual) {
+			return "", nil, fmt.Errorf("%w: active only supports eq and ne with true or false", scim.ErrInvalidFilter)
+		}
+		if value == (c.Op == scim.OpEqual) {
+			return "status = 'active'", nil, nil
+		}
+		return "status <> 'active'", nil, nil
+
+	case scimRoles:
+		value, ok := c.Value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %s takes a string", scim.ErrInvalidFilter, c.Attr)
+		}
+		const match = "EXISTS (SELECT 1 FROM unnest(roles) AS role WHERE "
+		switch c.Op {
+		case scim.OpEqual:
+			return match + "LOWER(role) = LOWER(?))", []interface{}{value}, nil
+		case scim.OpNotEqual:
+			return "NOT " + match + "LOWER(role) = LOWER(?))", []interface{}{value}, nil
+		case scim.OpContains:
+			return match + "role ILIKE ?)", []interface{}{"%" + escapeLike(value) + "%"}, nil
+		case scim.OpStartsWith:
+			return match + "role ILIKE ?)", []interface{}{escapeLike(value) + "%"}, nil
+		case scim.OpEndsWith:
+			return match + "role ILIKE ?)", []interface{}{"%" + escapeLike(value)}, nil
+		}
+		return "", nil, unsupported
+	}
+	return "", nil, unsupported
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *domain.Session) error
+	GetByID(id uint) (*domain.Session, error)
+	GetActiveByDevice(userID uint, deviceID string) (*domain.Session, error)
+	ListActiveByUser(userID uint) ([]domain.Session, error)
+	Update(session *domain.Session) error
+	Revoke(id uint) error
+	RevokeAllForUser(userID uint) error
+	CreateRefreshToken(token *domain.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error)
+	MarkRefreshTokenRotated(id uint) (bool, error)
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *domain.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) GetByID(id uint) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.First(&session, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetActiveByDevice(userID uint, deviceID string) (*domain.Session, error) {
+	var session domain.Session
+	err := r.db.Where("user_id = ? AND device_id = ? AND revoked_at IS NULL", userID, deviceID).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActiveByUser returns the user's sessions that are not revoked and
+// still hold a usable refresh token, most recently used first.
+func (r *sessionRepository) ListActiveByUser(userID uint) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Where("EXISTS (?)", r.db.Model(&domain.RefreshToken{}).
+			Select("1").
+			Where("refresh_tokens.session_id = sessions.id AND rotated_at IS NULL AND expires_at > ?", time.Now())).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) Update(session *domain.Session) error {
+	return r.db.Save(session).Error
+}
+
+func (r *sessionRepository) Revoke(id uint) error {
+	return r.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *sessionRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenRotated retires a refresh token. It reports false when the
+// token had already been rotated, which means it is being replayed.
+func (r *sessionRepository) MarkRefreshTokenRotated(id uint) (bool, error) {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL", id).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
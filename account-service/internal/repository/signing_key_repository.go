@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Create(key *domain.SigningKey) error
+	ListUsable(retiredAfter time.Time) ([]domain.SigningKey, error)
+	// Rotate makes key the signing key and retires the others, unless an
+	// active key created after dueBefore already exists. It reports whether
+	// key was stored.
+	Rotate(key *domain.SigningKey, dueBefore time.Time) (bool, error)
+}
+
+// signingKeyLock is the advisory lock that serializes rotation across
+// replicas.
+const signingKeyLock = 7340211
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Create(key *domain.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// ListUsable returns active keys and keys retired after the given time,
+// whose tokens may still be in circulation.
+func (r *signingKeyRepository) ListUsable(retiredAfter time.Time) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := r.db.Where("retired_at IS NULL OR retired_at > ?", retiredAfter).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) Rotate(key *domain.SigningKey, dueBefore time.Time) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Replicas that rotate at the same time wait here one after the
+		// other, and the later ones find the key the first one made.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLock).Error; err != nil {
+			return err
+		}
+
+		var fresh int64
+		if err := tx.Model(&domain.SigningKey{}).
+			Where("retired_at IS NULL AND created_at > ?", dueBefore).
+			Count(&fresh).Error; err != nil {
+			return err
+		}
+		if fresh > 0 {
+			return nil
+		}
+
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.SigningKey{}).
+			Where("kid <> ? AND retired_at IS NULL", key.KID).
+			Update("retired_at", time.Now()).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	return rotated, err
+}
@@ -2,18 +2,44 @@ package repository
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/scim"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
 	Create(user *domain.User) error
 	GetByID(id uint) (*domain.User, error)
 	GetByUsername(username string) (*domain.User, error)
+	GetByEmail(email string) (*domain.User, error)
+	ListByIDs(ids []uint) ([]domain.User, error)
 	Update(user *domain.User) error
 	Delete(id uint) error
-	List(offset, limit int) ([]domain.User, error)
+	// DeletePendingBefore deletes the accounts still pending verification
+	// or approval that were created before cutoff, and returns them.
+	DeletePendingBefore(cutoff time.Time) ([]domain.User, error)
+	GetDeletedByID(id uint) (*domain.User, error)
+	ListDeleted(offset, limit int) ([]domain.User, int64, error)
+	ListDeletedBefore(cutoff time.Time, limit int) ([]domain.User, error)
+	Restore(id uint, username string) error
+	Purge(user *domain.User) error
+	List(filter domain.UserFilter, offset, limit int) ([]domain.User, int64, error)
+	// ListSCIM lists live accounts of any status matching a SCIM filter, in
+	// id order. A nil filter matches every account; a negative limit
+	// returns all of them.
+	ListSCIM(filter scim.Expr, offset, limit int) ([]domain.User, int64, error)
+	// Each walks every matching account in id order, in batches.
+	Each(filter domain.UserFilter, fn func([]domain.User) error) error
+	// CreateBatch creates all the accounts in one transaction, or none.
+	CreateBatch(users []domain.User) error
+	// ExistingUsernames returns which of the names live accounts already use.
+	ExistingUsernames(usernames []string) ([]string, error)
+	// CountByRole counts the live active accounts holding the role.
+	CountByRole(role domain.Role) (int64, error)
 }
 
 type userRepository struct {
@@ -50,6 +76,28 @@ func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Where("LOWER(email) = LOWER(?)", email).Order("id").First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) ListByIDs(ids []uint) ([]domain.User, error) {
+	var users []domain.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *userRepository) Update(user *domain.User) error {
 	return r.db.Save(user).Error
 }
@@ -58,10 +106,244 @@ func (r *userRepository) Delete(id uint) error {
 	return r.db.Delete(&domain.User{}, id).Error
 }
 
-func (r *userRepository) List(offset, limit int) ([]domain.User, error) {
+func (r *userRepository) DeletePendingBefore(cutoff time.Time) ([]domain.User, error) {
+	var users []domain.User
+	// One statement, so an account verified or approved meanwhile is no
+	// longer pending and stays.
+	err := r.db.Clauses(clause.Returning{}).
+		Where("status IN ? AND created_at < ?",
+			[]domain.UserStatus{domain.UserPendingVerification, domain.UserPendingApproval}, cutoff).
+		Delete(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) GetDeletedByID(id uint) (*domain.User, error) {
+	var user domain.User
+	if err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) ListDeleted(offset, limit int) ([]domain.User, int64, error) {
+	query := r.db.Unscoped().Model(&domain.User{}).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []domain.User
+	if err := query.Order("deleted_at DESC, id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// ListDeletedBefore returns accounts deleted before cutoff, oldest first.
+func (r *userRepository) ListDeletedBefore(cutoff time.Time, limit int) ([]domain.User, error) {
 	var users []domain.User
-	if err := r.db.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	err := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Order("deleted_at, id").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
 		return nil, err
 	}
 	return users, nil
 }
+
+func (r *userRepository) Restore(id uint, username string) error {
+	return r.db.Unscoped().Model(&domain.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "username": username}).Error
+}
+
+// Purge removes a deleted account for good together with its credentials,
+// sessions and profiles. Failed sign-ins recorded under its name before it was
+// deleted are kept for the IP statistics but stripped of the username and
+// user agent. Audit entries are kept: they refer to the account by ID and
+// never record its username.
+func (r *userRepository) Purge(user *domain.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&domain.RefreshToken{},
+			&domain.Session{},
+			&domain.MFACredential{},
+			&domain.RecoveryCode{},
+			&domain.MFAChallenge{},
+			&domain.PasswordResetToken{},
+			&domain.VerificationCode{},
+			&domain.PasswordHistory{},
+			&domain.PatientProfile{},
+			&domain.DoctorProfile{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&domain.LoginAttempt{}).
+			Where("username = ? AND created_at <= ?", user.Username, user.DeletedAt.Time).
+			Updates(map[string]interface{}{"username": "", "user_agent": ""}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&domain.User{}, user.ID).Error
+	})
+}
+
+// MigrateUsernameIndex drops the unique constraint older schemas put on
+// every username, deleted or not. AutoMigrate creates its replacement,
+// which only covers live accounts.
+func MigrateUsernameIndex(db *gorm.DB) error {
+	for _, statement := range []string{
+		`ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key`,
+		`DROP INDEX IF EXISTS idx_users_username`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	userExportBatchSize = 500
+	userImportBatchSize = 100
+)
+
+// userSortColumns are the columns a listing may be sorted by.
+var userSortColumns = map[string]bool{
+	"id":         true,
+	"username":   true,
+	"first_name": true,
+	"last_name":  true,
+	"created_at": true,
+}
+
+// ValidUserSort reports whether sort names a column List can order by.
+func ValidUserSort(sort string) bool {
+	return sort == "" || userSortColumns[strings.TrimPrefix(sort, "-")]
+}
+
+func (r *userRepository) List(filter domain.UserFilter, offset, limit int) ([]domain.User, int64, error) {
+	query := r.filtered(filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "id"
+	if column := strings.TrimPrefix(filter.Sort, "-"); userSortColumns[column] {
+		order = column
+		if strings.HasPrefix(filter.Sort, "-") {
+			order += " DESC"
+		}
+		order += ", id"
+	}
+
+	var users []domain.User
+	if err := query.Order(order).Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) ListSCIM(filter scim.Expr, offset, limit int) ([]domain.User, int64, error) {
+	query := r.db.Model(&domain.User{})
+	if filter != nil {
+		condition, args, err := scimCondition(filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []domain.User{}
+	if limit == 0 {
+		return users, total, nil
+	}
+	if err := query.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) Each(filter domain.UserFilter, fn func([]domain.User) error) error {
+	var batch []domain.User
+	return r.filtered(filter).
+		Order("id").
+		FindInBatches(&batch, userExportBatchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *userRepository) filtered(filter domain.UserFilter) *gorm.DB {
+	query := r.db.Model(&domain.User{})
+	if filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		query = query.Where("username ILIKE ? OR (first_name || ' ' || last_name) ILIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("? = ANY(roles)", filter.Role)
+	}
+	status := filter.Status
+	if status == "" {
+		status = domain.UserActive
+	}
+	query = query.Where("status = ?", status)
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	return query
+}
+
+func (r *userRepository) CreateBatch(users []domain.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&users, userImportBatchSize).Error
+	})
+}
+
+func (r *userRepository) ExistingUsernames(usernames []string) ([]string, error) {
+	var existing []string
+	if len(usernames) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&domain.User{}).Where("username IN ?", usernames).Pluck("username", &existing).Error
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *userRepository) CountByRole(role domain.Role) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.User{}).Where("? = ANY(roles) AND status = ?", role, domain.UserActive).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"gorm.io/gorm"
+)
+
+type VerificationRepository interface {
+	Create(code *domain.VerificationCode) error
+	// GetLatest returns the newest unused code of the user.
+	GetLatest(userID uint) (*domain.VerificationCode, error)
+	IncrementAttempts(id uint) error
+	// MarkUsed consumes the code. It reports false if the code was already
+	// used, so two concurrent verifications cannot both succeed.
+	MarkUsed(id uint) (bool, error)
+	InvalidateAllForUser(userID uint) error
+	// CountForUser counts every code ever sent to the user.
+	CountForUser(userID uint) (int64, error)
+}
+
+type verificationRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationRepository(db *gorm.DB) VerificationRepository {
+	return &verificationRepository{db: db}
+}
+
+func (r *verificationRepository) Create(code *domain.VerificationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *verificationRepository) GetLatest(userID uint) (*domain.VerificationCode, error) {
+	var code domain.VerificationCode
+	err := r.db.Where("user_id = ? AND used_at IS NULL", userID).
+		Order("created_at DESC, id DESC").
+		First(&code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *verificationRepository) IncrementAttempts(id uint) error {
+	return r.db.Model(&domain.VerificationCode{}).
+		Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+func (r *verificationRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&domain.VerificationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *verificationRepository) InvalidateAllForUser(userID uint) error {
+	return r.db.Model(&domain.VerificationCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+func (r *verificationRepository) CountForUser(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.VerificationCode{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
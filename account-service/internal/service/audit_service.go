@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+)
+
+type AuditService interface {
+	Record(actor domain.Actor, action string, targetID uint, details string) error
+	List(filter domain.AuditFilter, offset, limit int) ([]domain.AuditEvent, error)
+	Export(filter domain.AuditFilter, fn func([]domain.AuditEvent) error) error
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) Record(actor domain.Actor, action string, targetID uint, details string) error {
+	return s.repo.Create(&domain.AuditEvent{
+		ActorID:        actor.UserID,
+		ActorClientID:  actor.ClientID,
+		ImpersonatorID: actor.ImpersonatorID,
+		TargetID:       targetID,
+		Action:         action,
+		Details:        details,
+		IP:             actor.IP,
+		UserAgent:      actor.UserAgent,
+	})
+}
+
+func (s *auditService) List(filter domain.AuditFilter, offset, limit int) ([]domain.AuditEvent, error) {
+	return s.repo.List(filter, offset, limit)
+}
+
+func (s *auditService) Export(filter domain.AuditFilter, fn func([]domain.AuditEvent) error) error {
+	return s.repo.Each(filter, fn)
+}
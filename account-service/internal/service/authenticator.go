@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/directory"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownUser is returned by an Authenticator that has no account
+	// with the username, so the next one in the chain is asked.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrDirectoryUnavailable is returned by SignIn when a directory could
+	// not be reached and no other authenticator knew the user.
+	ErrDirectoryUnavailable = errors.New("account directory is unavailable")
+	// ErrNoDirectoryRole is returned by SignIn for directory accounts that
+	// are in none of the groups mapped to a role.
+	ErrNoDirectoryRole = errors.New("directory account has no role in this system")
+	// ErrAccountSourceConflict is returned by SignIn when a directory
+	// account's username is already taken by an account of another source.
+	ErrAccountSourceConflict = errors.New("username belongs to an account from another source")
+	// ErrDirectoryAccount is returned when the password of a directory
+	// account is to be changed here rather than in the directory.
+	ErrDirectoryAccount = errors.New("password of a directory account is managed by the directory")
+)
+
+// Identity is who an Authenticator found behind a username and password.
+// The local authenticator returns the account itself in User; others only
+// describe it, and SignIn provisions or updates the account to match.
+type Identity struct {
+	Source    domain.UserSource
+	User      *domain.User
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Roles     []domain.Role
+}
+
+// Authenticator checks a username and password. It returns ErrUnknownUser
+// when it has no such account, ErrInvalidCredentials when the password is
+// wrong, and ErrDirectoryUnavailable when it cannot tell.
+type Authenticator interface {
+	Authenticate(username, password string) (*Identity, error)
+}
+
+type localAuthenticator struct {
+	repo repository.UserRepository
+}
+
+// NewLocalAuthenticator checks passwords against the bcrypt hashes of local
+// accounts. Directory accounts are left to their directory.
+func NewLocalAuthenticator(repo repository.UserRepository) Authenticator {
+	return &localAuthenticator{repo: repo}
+}
+
+func (a *localAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	user, err := a.repo.GetByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Source != domain.UserSourceLocal {
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Source: domain.UserSourceLocal, User: user}, nil
+}
+
+type directoryAuthenticator struct {
+	dir directory.Directory
+}
+
+// NewDirectoryAuthenticator binds against an LDAP directory and takes the
+// account's roles from its group memberships.
+func NewDirectoryAuthenticator(dir directory.Directory) Authenticator {
+	return &directoryAuthenticator{dir: dir}
+}
+
+func (a *directoryAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	entry, err := a.dir.Authenticate(username, password)
+	switch err {
+	case nil:
+	case directory.ErrUserNotFound:
+		return nil, ErrUnknownUser
+	case directory.ErrInvalidCredentials:
+		return nil, ErrInvalidCredentials
+	default:
+		log.Printf("Directory sign-in for %q failed: %v", username, err)
+		return nil, ErrDirectoryUnavailable
+	}
+
+	if len(entry.Roles) == 0 {
+		return nil, ErrNoDirectoryRole
+	}
+	return &Identity{
+		Source:    domain.UserSourceLDAP,
+		Username:  entry.Username,
+		Email:     entry.Email,
+		FirstName: entry.FirstName,
+		LastName:  entry.LastName,
+		Roles:     entry.Roles,
+	}, nil
+}
+
+// authenticate asks each authenticator in turn until one knows the user. A
+// directory that is down does not stop local accounts from signing in.
+func (s *userService) authenticate(username, password string) (*Identity, error) {
+	unknown := ErrUnknownUser
+	for _, authenticator := range s.authenticators {
+		identity, err := authenticator.Authenticate(username, password)
+		switch err {
+		case ErrUnknownUser:
+		case ErrDirectoryUnavailable:
+			unknown = err
+		default:
+			return identity, err
+		}
+	}
+	return nil, unknown
+}
+
+// accountFor returns the account an identity belongs to. Directory accounts
+// are created at their first sign-in and brought in line with the directory
+// at every later one.
+func (s *userService) accountFor(identity *Identity, client domain.ClientInfo) (*domain.User, error) {
+	if identity.User != nil {
+		return identity.User, nil
+	}
+
+	user, err := s.repo.GetByUsername(identity.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return s.provision(identity, client)
+	}
+	if user.Source != identity.Source {
+		log.Printf("Refusing %s sign-in for %q: the username belongs to a %s account", identity.Source, identity.Username, user.Source)
+		return nil, ErrAccountSourceConflict
+	}
+
+	actor := client.As(user.ID)
+	changed := false
+	for _, field := range []struct {
+		have *string
+		want string
+	}{
+		{&user.Email, identity.Email},
+		{&user.FirstName, identity.FirstName},
+		{&user.LastName, identity.LastName},
+	} {
+		if field.want != "" && *field.have != field.want {
+			*field.have = field.want
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.repo.Update(user); err != nil {
+			return nil, err
+		}
+		if err := s.audit.Record(actor, domain.AuditAccountUpdated, user.ID, string(identity.Source)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sync revokes older tokens at a whole second, so neither the
+	// session nor the token issued after it are affected.
+	if !rolesEqual(identity.Roles, user.Roles) {
+		if err := s.syncRoles(actor, user, identity.Roles); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// syncRoles gives a directory account the roles of its groups. The last
+// active admin keeps Admin when the directory takes it away, so nobody is
+// locked out of administration until another admin is appointed.
+func (s *userService) syncRoles(actor domain.Actor, user *domain.User, roles []domain.Role) error {
+	err := s.setRoles(actor, user, roles)
+	if err != ErrLastAdmin {
+		return err
+	}
+
+	log.Printf("Keeping the Admin role of %q, the last active admin, although the directory no longer grants it", user.Username)
+	roles = append(append([]domain.Role{}, roles...), domain.RoleAdmin)
+	if rolesEqual(roles, user.Roles) {
+		return nil
+	}
+	return s.setRoles(actor, user, roles)
+}
+
+// provision creates the account for a directory user. It gets a random
+// password nobody knows, which the local authenticator never checks.
+func (s *userService) provision(identity *Identity, client domain.ClientInfo) (*domain.User, error) {
+	if err := validateRoles(identity.Roles); err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		Username:  identity.Username,
+		Email:     identity.Email,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Roles:     identity.Roles,
+		Status:    domain.UserActive,
+		Source:    identity.Source,
+	}
+	plain, err := s.passwords.Generate(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.passwords.Set(user, plain); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	actor := client.As(user.ID)
+	if err := s.audit.Record(actor, domain.AuditAccountCreated, user.ID, ""); err != nil {
+		return nil, err
+	}
+	for _, role := range user.Roles {
+		if err := s.audit.Record(actor, domain.AuditRoleGranted, user.ID, string(role)); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
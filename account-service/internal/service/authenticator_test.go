@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/directory"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+// fakeDirectory knows a single account, jdoe, with the password "secret".
+type fakeDirectory struct {
+	roles []domain.Role
+}
+
+func (d *fakeDirectory) Authenticate(username, password string) (*directory.Entry, error) {
+	if username != "jdoe" {
+		return nil, directory.ErrUserNotFound
+	}
+	if password != "secret" {
+		return nil, directory.ErrInvalidCredentials
+	}
+	return &directory.Entry{
+		DN:        "uid=jdoe,dc=example,dc=org",
+		Username:  "jdoe",
+		Email:     "jdoe@example.org",
+		FirstName: "John",
+		LastName:  "Doe",
+		Roles:     d.roles,
+	}, nil
+}
+
+func newDirectoryUserService(t *testing.T, roles ...domain.Role) (*testUserService, *fakeDirectory) {
+	t.Helper()
+
+	ts := newTestUserService(t)
+	dir := &fakeDirectory{roles: roles}
+	ts.authenticators = []Authenticator{NewDirectoryAuthenticator(dir), NewLocalAuthenticator(ts.users)}
+	return ts, dir
+}
+
+func signInDirectory(t *testing.T, ts *testUserService) (*domain.SignInResponse, *domain.User) {
+	t.Helper()
+
+	signedIn, err := ts.SignIn(&domain.SignInRequest{Username: "jdoe", Password: "secret"}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	user, _ := ts.users.GetByUsername("jdoe")
+	return signedIn, user
+}
+
+func TestDirectoryRoleSyncKeepsNewToken(t *testing.T) {
+	ts, dir := newDirectoryUserService(t, domain.RoleDoctor)
+	before, _ := signInDirectory(t, ts)
+
+	waitForNextSecond()
+	dir.roles = []domain.Role{domain.RoleManager}
+	after, user := signInDirectory(t, ts)
+
+	if !rolesEqual(user.Roles, dir.roles) {
+		t.Errorf("roles = %v, want %v", user.Roles, dir.roles)
+	}
+	if _, err := ts.ValidateToken(after.AccessToken); err != nil {
+		t.Errorf("token issued by the sign-in that synced the roles: %v", err)
+	}
+	if _, err := ts.ValidateToken(before.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("token issued with the old roles: got %v, want %v", err, ErrTokenRevoked)
+	}
+	active := 0
+	for _, session := range ts.sessions.sessions {
+		if session.RevokedAt == nil {
+			active++
+		}
+	}
+	if active != 1 {
+		t.Errorf("%d active sessions, want the new one alone", active)
+	}
+}
+
+func TestDirectoryRoleSyncKeepsLastAdmin(t *testing.T) {
+	ts, dir := newDirectoryUserService(t, domain.RoleAdmin, domain.RoleDoctor)
+	signInDirectory(t, ts)
+
+	dir.roles = []domain.Role{domain.RoleDoctor}
+	_, user := signInDirectory(t, ts)
+	if want := []domain.Role{domain.RoleDoctor, domain.RoleAdmin}; !rolesEqual(user.Roles, want) {
+		t.Errorf("last admin's roles = %v, want %v", user.Roles, want)
+	}
+
+	ts.addUser(t, "admin", "Correct-Horse-42", domain.RoleAdmin)
+	_, user = signInDirectory(t, ts)
+	if !rolesEqual(user.Roles, dir.roles) {
+		t.Errorf("roles once another admin exists = %v, want %v", user.Roles, dir.roles)
+	}
+}
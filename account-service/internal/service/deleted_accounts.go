@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+var ErrRetentionNotElapsed = errors.New("account is still within its retention period")
+
+// purgeBatchSize bounds how many accounts one PurgeExpiredUsers run removes.
+const purgeBatchSize = 100
+
+func (s *userService) ListDeletedUsers(offset, limit int) (*domain.UserPage, error) {
+	users, total, err := s.repo.ListDeleted(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UserPage{Items: users, Total: total}, nil
+}
+
+// RestoreUser undeletes an account. If its username now belongs to someone
+// else the request has to supply a new one.
+func (s *userService) RestoreUser(actor domain.Actor, id uint, req *domain.RestoreUserRequest) error {
+	user, err := s.repo.GetDeletedByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	username := user.Username
+	if req.Username != "" {
+		username = req.Username
+	}
+	existingUser, err := s.repo.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+	if existingUser != nil {
+		return ErrUserAlreadyExists
+	}
+
+	if err := s.repo.Restore(user.ID, username); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditAccountRestored, user.ID, "")
+}
+
+// PurgeUser permanently removes a deleted account once its retention
+// period is over.
+func (s *userService) PurgeUser(actor domain.Actor, id uint) error {
+	user, err := s.repo.GetDeletedByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if time.Since(user.DeletedAt.Time) < s.retention {
+		return ErrRetentionNotElapsed
+	}
+	return s.purge(actor, user)
+}
+
+// PurgeExpiredUsers removes every account deleted longer than the
+// retention period ago and reports how many it removed.
+func (s *userService) PurgeExpiredUsers(actor domain.Actor) (int, error) {
+	purged := 0
+	for {
+		users, err := s.repo.ListDeletedBefore(time.Now().Add(-s.retention), purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for i := range users {
+			if err := s.purge(actor, &users[i]); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (s *userService) purge(actor domain.Actor, user *domain.User) error {
+	if err := s.repo.Purge(user); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditAccountPurged, user.ID, "")
+}
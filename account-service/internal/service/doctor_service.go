@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+)
+
+var (
+	ErrDoctorNotFound        = errors.New("doctor not found")
+	ErrDoctorProfileNotFound = errors.New("doctor profile not found")
+)
+
+type DoctorService interface {
+	ListDoctors(filter domain.DoctorFilter, offset, limit int) (*domain.DoctorPage, error)
+	GetDoctor(id uint) (*domain.Doctor, error)
+	GetProfiles(userIDs []uint) (map[uint]*domain.DoctorProfile, error)
+	SaveProfile(actor domain.Actor, userID uint, req *domain.DoctorProfileRequest) (*domain.DoctorProfile, error)
+	DeleteProfile(actor domain.Actor, userID uint) error
+}
+
+type doctorService struct {
+	repo  repository.DoctorRepository
+	audit AuditService
+}
+
+func NewDoctorService(repo repository.DoctorRepository, audit AuditService) DoctorService {
+	return &doctorService{
+		repo:  repo,
+		audit: audit,
+	}
+}
+
+func (s *doctorService) ListDoctors(filter domain.DoctorFilter, offset, limit int) (*domain.DoctorPage, error) {
+	users, total, err := s.repo.List(filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	byUser, err := s.GetProfiles(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.DoctorPage{Items: make([]domain.Doctor, 0, len(users)), Total: total}
+	for _, user := range users {
+		page.Items = append(page.Items, newDoctor(&user, byUser[user.ID]))
+	}
+	return page, nil
+}
+
+// GetProfiles returns the doctor profiles of the given users, keyed by user
+// ID. Users without one are missing from the map.
+func (s *doctorService) GetProfiles(userIDs []uint) (map[uint]*domain.DoctorProfile, error) {
+	profiles, err := s.repo.ListProfiles(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[uint]*domain.DoctorProfile, len(profiles))
+	for i := range profiles {
+		byUser[profiles[i].UserID] = &profiles[i]
+	}
+	return byUser, nil
+}
+
+func (s *doctorService) GetDoctor(id uint) (*domain.Doctor, error) {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrDoctorNotFound
+	}
+
+	profile, err := s.repo.GetProfile(id)
+	if err != nil {
+		return nil, err
+	}
+	doctor := newDoctor(user, profile)
+	return &doctor, nil
+}
+
+// newDoctor takes the public fields of a doctor's account for the
+// directory.
+func newDoctor(user *domain.User, profile *domain.DoctorProfile) domain.Doctor {
+	return domain.Doctor{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Roles:     user.Roles,
+		Profile:   profile,
+	}
+}
+
+// SaveProfile creates the doctor's profile or replaces it as a whole. Only
+// accounts with the Doctor role can have one.
+func (s *doctorService) SaveProfile(actor domain.Actor, userID uint, req *domain.DoctorProfileRequest) (*domain.DoctorProfile, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrDoctorNotFound
+	}
+
+	profile, err := s.repo.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &domain.DoctorProfile{UserID: userID}
+	}
+
+	profile.Specializations = normalizeSpecializations(req.Specializations)
+	profile.LicenseNumber = strings.TrimSpace(req.LicenseNumber)
+	profile.HospitalIDs = uniqueIDs(req.HospitalIDs)
+	profile.Bio = req.Bio
+
+	if err := s.repo.SaveProfile(profile); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditDoctorProfileUpdated, userID, ""); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *doctorService) DeleteProfile(actor domain.Actor, userID uint) error {
+	deleted, err := s.repo.DeleteProfile(userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDoctorProfileNotFound
+	}
+	return s.audit.Record(actor, domain.AuditDoctorProfileDeleted, userID, "")
+}
+
+// normalizeSpecializations trims the names and drops blanks and
+// case-insensitive duplicates, keeping the order given.
+func normalizeSpecializations(specializations []string) []string {
+	seen := make(map[string]bool, len(specializations))
+	result := make([]string, 0, len(specializations))
+	for _, specialization := range specializations {
+		specialization = strings.TrimSpace(specialization)
+		key := strings.ToLower(specialization)
+		if specialization == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, specialization)
+	}
+	return result
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func TestDoctorLeavesOutPrivateFields(t *testing.T) {
+	ts := newTestUserService(t)
+	doctor := ts.addUser(t, "dr.house", "Correct-Horse-42", domain.RoleDoctor)
+	doctor.Email = "house@example.org"
+	ts.users.Update(doctor)
+	svc := NewDoctorService(&fakeDoctors{
+		users:    ts.users,
+		profiles: []domain.DoctorProfile{{UserID: doctor.ID, Bio: "Diagnostician"}},
+	}, ts.audit)
+
+	found, err := svc.GetDoctor(doctor.ID)
+	if err != nil {
+		t.Fatalf("get doctor: %v", err)
+	}
+	data, err := json.Marshal(found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := []string{"first_name", "id", "last_name", "profile", "roles"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("doctor has fields %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,570 @@
+package service
+
+import (
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/password"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/scim"
+	"gorm.io/gorm"
+)
+
+// The fakes below keep just enough state in memory for the service tests.
+// They embed the repository interfaces, so a test that reaches a method a
+// fake does not implement panics rather than passing by accident.
+
+type fakeUsers struct {
+	repository.UserRepository
+	users  map[uint]*domain.User
+	nextID uint
+}
+
+func newFakeUsers() *fakeUsers {
+	return &fakeUsers{users: map[uint]*domain.User{}}
+}
+
+func (r *fakeUsers) Create(user *domain.User) error {
+	r.nextID++
+	user.ID = r.nextID
+	user.CreatedAt = time.Now()
+	if user.Status == "" {
+		user.Status = domain.UserActive
+	}
+	if user.Source == "" {
+		user.Source = domain.UserSourceLocal
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUsers) GetByID(id uint) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return nil, nil
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *fakeUsers) GetByUsername(username string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Username == username && !user.DeletedAt.Valid {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) Update(user *domain.User) error {
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUsers) Delete(id uint) error {
+	if user, ok := r.users[id]; ok {
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (r *fakeUsers) DeletePendingBefore(cutoff time.Time) ([]domain.User, error) {
+	var deleted []domain.User
+	for _, user := range r.users {
+		pending := user.Status == domain.UserPendingVerification || user.Status == domain.UserPendingApproval
+		if pending && !user.DeletedAt.Valid && user.CreatedAt.Before(cutoff) {
+			user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			deleted = append(deleted, *user)
+		}
+	}
+	return deleted, nil
+}
+
+// ListSCIM understands the filters on roles that SCIM groups list their
+// members with.
+func (r *fakeUsers) ListSCIM(filter scim.Expr, offset, limit int) ([]domain.User, int64, error) {
+	var users []domain.User
+	for _, user := range r.users {
+		roles := make([]string, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			roles = append(roles, string(role))
+		}
+		if !user.DeletedAt.Valid && (filter == nil || scim.Match(filter, map[string][]string{"roles": roles})) {
+			users = append(users, *user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, int64(len(users)), nil
+}
+
+func (r *fakeUsers) CountByRole(role domain.Role) (int64, error) {
+	var count int64
+	for _, user := range r.users {
+		if user.Status == domain.UserActive && user.HasRole(role) && !user.DeletedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakeSessions struct {
+	repository.SessionRepository
+	sessions []*domain.Session
+	tokens   []*domain.RefreshToken
+}
+
+func (r *fakeSessions) Create(session *domain.Session) error {
+	session.ID = uint(len(r.sessions) + 1)
+	session.CreatedAt = time.Now()
+	r.sessions = append(r.sessions, session)
+	return nil
+}
+
+func (r *fakeSessions) GetByID(id uint) (*domain.Session, error) {
+	for _, session := range r.sessions {
+		if session.ID == id {
+			found := *session
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSessions) GetActiveByDevice(userID uint, deviceID string) (*domain.Session, error) {
+	return nil, nil
+}
+
+func (r *fakeSessions) Update(session *domain.Session) error {
+	for i, stored := range r.sessions {
+		if stored.ID == session.ID {
+			updated := *session
+			r.sessions[i] = &updated
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessions) Revoke(id uint) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.ID == id && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessions) RevokeAllForUser(userID uint) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessions) CreateRefreshToken(token *domain.RefreshToken) error {
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakeSessions) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSessions) MarkRefreshTokenRotated(id uint) (bool, error) {
+	for _, token := range r.tokens {
+		if token.ID == id && token.RotatedAt == nil {
+			now := time.Now()
+			token.RotatedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeRevocations matches tokens the way the SQL in revocationRepository
+// does.
+type fakeRevocations struct {
+	repository.RevocationRepository
+	revocations []domain.TokenRevocation
+}
+
+func (r *fakeRevocations) Create(revocation *domain.TokenRevocation) error {
+	if revocation.CreatedAt.IsZero() {
+		revocation.CreatedAt = time.Now()
+	}
+	r.revocations = append(r.revocations, *revocation)
+	return nil
+}
+
+func (r *fakeRevocations) IsRevoked(jti, clientID string, userID, sessionID uint, issuedAt time.Time) (bool, error) {
+	for _, rev := range r.revocations {
+		switch {
+		case !rev.ExpiresAt.After(time.Now()):
+		case rev.JTI != "" && rev.JTI == jti,
+			rev.SessionID != 0 && rev.SessionID == sessionID,
+			rev.ClientID != "" && rev.ClientID == clientID,
+			rev.JTI == "" && rev.SessionID == 0 && rev.ClientID == "" &&
+				rev.UserID == userID && rev.CreatedAt.After(issuedAt):
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type fakeLoginAttempts struct {
+	repository.LoginAttemptRepository
+	attempts []domain.LoginAttempt
+}
+
+func (r *fakeLoginAttempts) Create(attempt *domain.LoginAttempt) error {
+	attempt.CreatedAt = time.Now()
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *fakeLoginAttempts) FailuresByUsername(username string, since time.Time) (*repository.FailureStats, error) {
+	return r.failures(func(a domain.LoginAttempt) bool { return a.Username == username }, since), nil
+}
+
+func (r *fakeLoginAttempts) FailuresByIP(ip string, since time.Time) (*repository.FailureStats, error) {
+	return r.failures(func(a domain.LoginAttempt) bool { return a.IP == ip }, since), nil
+}
+
+func (r *fakeLoginAttempts) failures(match func(domain.LoginAttempt) bool, since time.Time) *repository.FailureStats {
+	stats := &repository.FailureStats{}
+	for _, attempt := range r.attempts {
+		if match(attempt) && attempt.CreatedAt.After(since) {
+			stats.Count++
+			if attempt.CreatedAt.After(stats.Last) {
+				stats.Last = attempt.CreatedAt
+			}
+		}
+	}
+	return stats
+}
+
+func (r *fakeLoginAttempts) ClearFailures(username string) error {
+	kept := r.attempts[:0]
+	for _, attempt := range r.attempts {
+		if attempt.Username != username {
+			kept = append(kept, attempt)
+		}
+	}
+	r.attempts = kept
+	return nil
+}
+
+type fakeMFA struct {
+	repository.MFARepository
+	credentials []*domain.MFACredential
+	roles       []domain.Role
+}
+
+func (r *fakeMFA) GetCredential(userID uint) (*domain.MFACredential, error) {
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			found := *credential
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeMFA) SaveCredential(credential *domain.MFACredential) error {
+	for i, stored := range r.credentials {
+		if stored.UserID == credential.UserID {
+			saved := *credential
+			r.credentials[i] = &saved
+			return nil
+		}
+	}
+	credential.ID = uint(len(r.credentials) + 1)
+	saved := *credential
+	r.credentials = append(r.credentials, &saved)
+	return nil
+}
+
+func (r *fakeMFA) MarkStepUsed(credentialID uint, step int64) (bool, error) {
+	for _, credential := range r.credentials {
+		if credential.ID == credentialID {
+			if step <= credential.LastUsedStep {
+				return false, nil
+			}
+			credential.LastUsedStep = step
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeMFA) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeMFA) DeleteCredential(userID uint) error {
+	kept := r.credentials[:0]
+	for _, credential := range r.credentials {
+		if credential.UserID != userID {
+			kept = append(kept, credential)
+		}
+	}
+	r.credentials = kept
+	return nil
+}
+
+func (r *fakeMFA) ListRequiredRoles() ([]domain.Role, error) {
+	return r.roles, nil
+}
+
+func (r *fakeMFA) SetRequiredRoles(roles []domain.Role) error {
+	r.roles = roles
+	return nil
+}
+
+type fakeVerifications struct {
+	repository.VerificationRepository
+	codes []*domain.VerificationCode
+}
+
+func (r *fakeVerifications) Create(code *domain.VerificationCode) error {
+	code.ID = uint(len(r.codes) + 1)
+	code.CreatedAt = time.Now()
+	r.codes = append(r.codes, code)
+	return nil
+}
+
+func (r *fakeVerifications) GetLatest(userID uint) (*domain.VerificationCode, error) {
+	for i := len(r.codes) - 1; i >= 0; i-- {
+		if code := r.codes[i]; code.UserID == userID && code.UsedAt == nil {
+			found := *code
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeVerifications) IncrementAttempts(id uint) error {
+	r.codes[id-1].Attempts++
+	return nil
+}
+
+func (r *fakeVerifications) MarkUsed(id uint) (bool, error) {
+	code := r.codes[id-1]
+	if code.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return true, nil
+}
+
+func (r *fakeVerifications) InvalidateAllForUser(userID uint) error {
+	now := time.Now()
+	for _, code := range r.codes {
+		if code.UserID == userID && code.UsedAt == nil {
+			code.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeVerifications) CountForUser(userID uint) (int64, error) {
+	var count int64
+	for _, code := range r.codes {
+		if code.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakePasswordHistory struct {
+	repository.PasswordHistoryRepository
+	entries []domain.PasswordHistory
+}
+
+func (r *fakePasswordHistory) Create(entry *domain.PasswordHistory) error {
+	entry.CreatedAt = time.Now()
+	r.entries = append([]domain.PasswordHistory{*entry}, r.entries...)
+	return nil
+}
+
+func (r *fakePasswordHistory) ListRecent(userID uint, limit int) ([]domain.PasswordHistory, error) {
+	var recent []domain.PasswordHistory
+	for _, entry := range r.entries {
+		if entry.UserID == userID && len(recent) < limit {
+			recent = append(recent, entry)
+		}
+	}
+	return recent, nil
+}
+
+type fakeSigningKeys struct {
+	repository.SigningKeyRepository
+	keys []domain.SigningKey
+}
+
+func (r *fakeSigningKeys) ListUsable(retiredAfter time.Time) ([]domain.SigningKey, error) {
+	keys := append([]domain.SigningKey(nil), r.keys...)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *fakeSigningKeys) Rotate(key *domain.SigningKey, dueBefore time.Time) (bool, error) {
+	now := time.Now()
+	for i := range r.keys {
+		if r.keys[i].RetiredAt == nil {
+			r.keys[i].RetiredAt = &now
+		}
+	}
+	key.CreatedAt = now
+	r.keys = append(r.keys, *key)
+	return true, nil
+}
+
+type fakeDoctors struct {
+	repository.DoctorRepository
+	users    *fakeUsers
+	profiles []domain.DoctorProfile
+}
+
+func (r *fakeDoctors) GetByID(id uint) (*domain.User, error) {
+	user, err := r.users.GetByID(id)
+	if user == nil || !user.HasRole(domain.RoleDoctor) {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *fakeDoctors) GetProfile(userID uint) (*domain.DoctorProfile, error) {
+	for _, profile := range r.profiles {
+		if profile.UserID == userID {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakePatients struct {
+	repository.PatientRepository
+	profiles []domain.PatientProfile
+}
+
+func (r *fakePatients) GetByUserID(userID uint) (*domain.PatientProfile, error) {
+	for _, profile := range r.profiles {
+		if profile.UserID == userID {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePatients) Save(profile *domain.PatientProfile) error {
+	r.profiles = append(r.profiles, *profile)
+	return nil
+}
+
+type fakeAudit struct {
+	AuditService
+	events []domain.AuditEvent
+}
+
+func (a *fakeAudit) Record(actor domain.Actor, action string, targetID uint, details string) error {
+	a.events = append(a.events, domain.AuditEvent{
+		ActorID:        actor.UserID,
+		ActorClientID:  actor.ClientID,
+		ImpersonatorID: actor.ImpersonatorID,
+		TargetID:       targetID,
+		Action:         action,
+		Details:        details,
+	})
+	return nil
+}
+
+// testUserService is a userService over the fakes, with local sign-in and
+// the default password policy.
+type testUserService struct {
+	*userService
+	users       *fakeUsers
+	sessions    *fakeSessions
+	revocations *fakeRevocations
+	logins      *fakeLoginAttempts
+	verify      *fakeVerifications
+	mfa         *fakeMFA
+	audit       *fakeAudit
+}
+
+// pendingAccountTTL is how long pending accounts last in the test service.
+const pendingAccountTTL = 7 * 24 * time.Hour
+
+func newTestUserService(t *testing.T) *testUserService {
+	t.Helper()
+
+	keys := NewKeyService(&fakeSigningKeys{}, time.Hour)
+	if err := keys.Sync(); err != nil {
+		t.Fatalf("sync keys: %v", err)
+	}
+
+	ts := &testUserService{
+		users:       newFakeUsers(),
+		sessions:    &fakeSessions{},
+		revocations: &fakeRevocations{},
+		logins:      &fakeLoginAttempts{},
+		verify:      &fakeVerifications{},
+		mfa:         &fakeMFA{},
+		audit:       &fakeAudit{},
+	}
+	ts.userService = NewUserService(
+		ts.users,
+		ts.sessions,
+		ts.revocations,
+		ts.mfa,
+		ts.logins,
+		ts.verify,
+		NewPasswords(password.DefaultPolicy(), &fakePasswordHistory{}),
+		ts.audit,
+		keys,
+		0,
+		pendingAccountTTL,
+		domain.RegistrationOpen,
+		notify.NewLogNotifier(io.Discard),
+		[]Authenticator{NewLocalAuthenticator(ts.users)},
+	).(*userService)
+	return ts
+}
+
+// addUser creates an active local account with the password.
+func (ts *testUserService) addUser(t *testing.T, username, plain string, roles ...domain.Role) *domain.User {
+	t.Helper()
+
+	user := &domain.User{
+		Username:  username,
+		FirstName: "Test",
+		LastName:  "User",
+		Roles:     roles,
+	}
+	if err := ts.passwords.Set(user, plain); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	if err := ts.users.Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+)
+
+var (
+	// ErrImpersonationForbidden is returned for changes an impersonation
+	// token may not make: passwords, roles, and further impersonation.
+	ErrImpersonationForbidden = errors.New("not allowed while impersonating")
+	ErrCannotImpersonate      = errors.New("this account cannot be impersonated")
+)
+
+const impersonationTTL = 15 * time.Minute
+
+// Impersonate issues an access token that acts as the user on behalf of the
+// admin in actor. The token carries the admin in its act claim, has no
+// refresh token, and dies with the admin's session. Other admins cannot be
+// impersonated, so the token never grants more than the user has.
+func (s *userService) Impersonate(actor domain.Actor, userID uint, req *domain.ImpersonateRequest) (*domain.ImpersonationResponse, error) {
+	if actor.ImpersonatorID != 0 {
+		return nil, ErrImpersonationForbidden
+	}
+
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == actor.UserID || user.HasRole(domain.RoleAdmin) {
+		return nil, ErrCannotImpersonate
+	}
+
+	token, err := signAccessToken(s.keys, &auth.Claims{
+		UserID: user.ID,
+		Roles:  user.Roles,
+		Act: &auth.ActorClaim{
+			UserID:    actor.UserID,
+			SessionID: actor.SessionID,
+		},
+	}, "", impersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(actor, domain.AuditImpersonationStarted, user.ID, req.Reason); err != nil {
+		return nil, err
+	}
+	return &domain.ImpersonationResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(impersonationTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/auth"
+)
+
+type KeyService interface {
+	Sync() error
+	SigningKey() (*auth.Key, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	JWKS() auth.JWKS
+}
+
+// keyResyncInterval is how often at most tokens signed with an unknown key
+// make the service reload its keys.
+const keyResyncInterval = 10 * time.Second
+
+type keyService struct {
+	repo             repository.SigningKeyRepository
+	keys             *auth.KeySet
+	rotationInterval time.Duration
+
+	mu         sync.Mutex
+	resyncedAt time.Time
+}
+
+func NewKeyService(repo repository.SigningKeyRepository, rotationInterval time.Duration) KeyService {
+	return &keyService{
+		repo:             repo,
+		keys:             auth.NewKeySet(),
+		rotationInterval: rotationInterval,
+	}
+}
+
+// Sync reloads the key set from the database and rotates the signing key
+// once it is older than the rotation interval. It is safe to call from
+// several replicas: rotation is serialized in the database, so replicas
+// that find the key due at the same time create only one new key.
+func (s *keyService) Sync() error {
+	stored, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if needsRotation(stored, s.rotationInterval) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		if stored, err = s.load(); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]*auth.Key, 0, len(stored))
+	for _, key := range stored {
+		privateKey, err := auth.DecodePrivateKey(key.PrivateKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, &auth.Key{
+			ID:         key.KID,
+			PrivateKey: privateKey,
+			CreatedAt:  key.CreatedAt,
+			RetiredAt:  key.RetiredAt,
+		})
+	}
+	s.keys.Replace(keys)
+
+	return nil
+}
+
+func (s *keyService) SigningKey() (*auth.Key, error) {
+	return s.keys.Signing()
+}
+
+// Keyfunc resolves the key a token was signed with. A key that another
+// replica has just rotated in is not known here until the next Sync, so an
+// unknown kid reloads the keys first.
+func (s *keyService) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key, err := s.keys.Keyfunc(token)
+	if !errors.Is(err, auth.ErrUnknownKey) || !s.resync() {
+		return key, err
+	}
+	return s.keys.Keyfunc(token)
+}
+
+// resync reloads the keys unless that was done less than keyResyncInterval
+// ago, so tokens with made-up kids cannot keep the database busy. It
+// reports whether the keys were reloaded.
+func (s *keyService) resync() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.resyncedAt) < keyResyncInterval {
+		return false
+	}
+	s.resyncedAt = time.Now()
+	if err := s.Sync(); err != nil {
+		log.Printf("Failed to sync signing keys: %v", err)
+		return false
+	}
+	return true
+}
+
+func (s *keyService) JWKS() auth.JWKS {
+	return s.keys.JWKS()
+}
+
+// load returns the active keys together with retired keys that may still
+// have unexpired access tokens signed with them.
+func (s *keyService) load() ([]domain.SigningKey, error) {
+	return s.repo.ListUsable(time.Now().Add(-accessTokenTTL))
+}
+
+func (s *keyService) rotate() error {
+	key, err := auth.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.Rotate(&domain.SigningKey{
+		KID:        key.ID,
+		PrivateKey: auth.EncodePrivateKey(key.PrivateKey),
+	}, time.Now().Add(-s.rotationInterval))
+	return err
+}
+
+func needsRotation(keys []domain.SigningKey, interval time.Duration) bool {
+	for _, key := range keys {
+		if key.RetiredAt == nil {
+			return time.Since(key.CreatedAt) >= interval
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/auth"
+)
+
+func signWith(t *testing.T, keys KeyService) string {
+	t.Helper()
+
+	key, err := keys.SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyfuncPicksUpKeysRotatedByAnotherReplica(t *testing.T) {
+	repo := &fakeSigningKeys{}
+	rotating := NewKeyService(repo, time.Hour)
+	other := NewKeyService(repo, time.Hour)
+	for _, keys := range []KeyService{rotating, other} {
+		if err := keys.Sync(); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+	}
+
+	if err := rotating.(*keyService).rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := rotating.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if _, err := jwt.Parse(signWith(t, rotating), other.Keyfunc); err != nil {
+		t.Errorf("token signed with the new key: %v", err)
+	}
+
+	_, err := other.Keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": "made-up"}})
+	if !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("made-up kid: got %v, want %v", err, auth.ErrUnknownKey)
+	}
+}
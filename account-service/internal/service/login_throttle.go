@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+const (
+	// Failures per username are counted over a day. A few mistakes only slow
+	// the next attempt down; after loginLockoutThreshold the account is
+	// locked, for twice as long with every further failure.
+	loginFailureWindow    = 24 * time.Hour
+	loginDelayThreshold   = 3
+	loginLockoutThreshold = 5
+	loginLockoutBase      = 15 * time.Minute
+	loginLockoutMax       = 24 * time.Hour
+
+	// One address guessing across many usernames is cut off as a whole.
+	ipFailureWindow    = time.Hour
+	ipLockoutThreshold = 20
+	ipLockoutDuration  = 15 * time.Minute
+)
+
+// ThrottledError is returned by SignIn while the username or the client
+// address is locked out.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed sign-in attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// checkThrottle rejects the attempt while an earlier run of failures for
+// the username or IP still holds it back.
+func (s *userService) checkThrottle(username, ip string) error {
+	now := time.Now()
+
+	userFailures, err := s.loginRepo.FailuresByUsername(username, now.Add(-loginFailureWindow))
+	if err != nil {
+		return err
+	}
+	until := userFailures.Last.Add(usernameBackoff(userFailures.Count))
+
+	if ip != "" {
+		ipFailures, err := s.loginRepo.FailuresByIP(ip, now.Add(-ipFailureWindow))
+		if err != nil {
+			return err
+		}
+		if ipFailures.Count >= ipLockoutThreshold {
+			if ipUntil := ipFailures.Last.Add(ipLockoutDuration); ipUntil.After(until) {
+				until = ipUntil
+			}
+		}
+	}
+
+	if until.After(now) {
+		return &ThrottledError{RetryAfter: until.Sub(now)}
+	}
+	return nil
+}
+
+func usernameBackoff(failures int64) time.Duration {
+	switch {
+	case failures >= loginLockoutThreshold:
+		backoff := loginLockoutBase
+		for i := int64(loginLockoutThreshold); i < failures && backoff < loginLockoutMax; i++ {
+			backoff *= 2
+		}
+		if backoff > loginLockoutMax {
+			backoff = loginLockoutMax
+		}
+		return backoff
+	case failures >= loginDelayThreshold:
+		return time.Second << (failures - loginDelayThreshold)
+	default:
+		return 0
+	}
+}
+
+// recordFailure counts a failed sign-in towards the throttle and audits it
+// against the account, when the username matched one. Only the login attempt
+// keeps the username, so Purge can scrub it; the audit entry has the reason.
+func (s *userService) recordFailure(username string, userID uint, client domain.ClientInfo, reason string) error {
+	if err := s.loginRepo.Create(&domain.LoginAttempt{
+		Username:  username,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	}); err != nil {
+		return err
+	}
+	return s.audit.Record(client.As(0), domain.AuditSignInFailed, userID, reason)
+}
+
+// UnlockUser clears the failures counted against the user so they can sign
+// in again straight away.
+func (s *userService) UnlockUser(actor domain.Actor, id uint) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.loginRepo.ClearFailures(user.Username); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditAccountUnlocked, user.ID, "")
+}
+
+func (s *userService) ListLoginAttempts(username, ip string, offset, limit int) ([]domain.LoginAttempt, error) {
+	return s.loginRepo.List(username, ip, offset, limit)
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/totp"
+)
+
+var (
+	ErrInvalidChallenge  = errors.New("invalid or expired mfa challenge")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa is not enrolled")
+)
+
+const (
+	mfaIssuer            = "Hospital"
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaMaxAttempts       = 5
+	mfaClockSkew         = 1
+	recoveryCodeCount    = 10
+	recoveryCodeHalfSize = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// completeSignIn issues tokens for a user whose password checked out, or
+// a challenge when the account needs a second factor first. Failed sign-ins
+// are cleared only once tokens are issued.
+func (s *userService) completeSignIn(user *domain.User, client domain.ClientInfo) (*domain.SignInResponse, error) {
+	credential, err := s.mfaRepo.GetCredential(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrolled := credential.Confirmed()
+	required := enrolled
+	if !enrolled {
+		if required, err = s.mfaRequiredFor(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if !required {
+		if err := s.loginRepo.ClearFailures(user.Username); err != nil {
+			return nil, err
+		}
+		session, err := s.startSession(user.ID, client)
+		if err != nil {
+			return nil, err
+		}
+		tokens, err := s.issueTokens(user, session)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.audit.Record(client.As(user.ID), domain.AuditSignIn, user.ID, ""); err != nil {
+			return nil, err
+		}
+		return &domain.SignInResponse{TokenResponse: tokens}, nil
+	}
+
+	challengeToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.CreateChallenge(&domain.MFAChallenge{
+		UserID:     user.ID,
+		TokenHash:  hashToken(challengeToken),
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+		Enrollment: !enrolled,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &domain.SignInResponse{
+		MFARequired:           true,
+		MFAEnrollmentRequired: !enrolled,
+		ChallengeToken:        challengeToken,
+		ExpiresIn:             int64(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+func (s *userService) mfaRequiredFor(user *domain.User) (bool, error) {
+	roles, err := s.mfaRepo.ListRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	for _, required := range roles {
+		for _, role := range user.Roles {
+			if role == required {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// VerifyMFA completes a two-step sign-in. During a forced enrollment the
+// code confirms the new credential and the recovery codes are returned
+// along with the tokens. Wrong codes count towards the sign-in throttle of
+// the account, so new challenges do not buy more guesses.
+func (s *userService) VerifyMFA(req *domain.MFAVerifyRequest, client domain.ClientInfo) (*domain.MFAVerifyResponse, error) {
+	challenge, err := s.activeChallenge(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.checkThrottle(user.Username, client.IP); err != nil {
+		return nil, err
+	}
+
+	credential, err := s.mfaRepo.GetCredential(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	ok, err := s.checkSecondFactor(credential, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.mfaRepo.IncrementChallengeAttempts(challenge.ID); err != nil {
+			return nil, err
+		}
+		if err := s.recordFailure(user.Username, user.ID, client, domain.LoginFailureInvalidMFACode); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+
+	used, err := s.mfaRepo.MarkChallengeUsed(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidChallenge
+	}
+	if err := s.loginRepo.ClearFailures(user.Username); err != nil {
+		return nil, err
+	}
+
+	actor := client.As(user.ID)
+	response := &domain.MFAVerifyResponse{}
+	if !credential.Confirmed() {
+		if response.RecoveryCodes, err = s.confirmCredential(actor, credential); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := s.startSession(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+	if response.TokenResponse, err = s.issueTokens(user, session); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditSignIn, user.ID, "mfa"); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// EnrollMFAWithChallenge starts enrollment for a user who cannot sign in
+// until they set up the MFA their role requires.
+func (s *userService) EnrollMFAWithChallenge(challengeToken string) (*domain.MFAEnrollResponse, error) {
+	challenge, err := s.activeChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Enrollment {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return s.enrollMFA(challenge.UserID)
+}
+
+// EnrollMFA creates a new, unconfirmed TOTP secret. Enrolling again before
+// confirming replaces the pending secret.
+func (s *userService) EnrollMFA(actor domain.Actor) (*domain.MFAEnrollResponse, error) {
+	if actor.ImpersonatorID != 0 {
+		return nil, ErrImpersonationForbidden
+	}
+	return s.enrollMFA(actor.UserID)
+}
+
+func (s *userService) enrollMFA(userID uint) (*domain.MFAEnrollResponse, error) {
+	user, err := s.repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	credential, err := s.mfaRepo.GetCredential(userID)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if credential == nil {
+		credential = &domain.MFACredential{UserID: userID}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	credential.Secret = secret
+	credential.LastUsedStep = 0
+	if err := s.mfaRepo.SaveCredential(credential); err != nil {
+		return nil, err
+	}
+
+	return &domain.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+func (s *userService) ConfirmMFA(actor domain.Actor, code string) ([]string, error) {
+	if actor.ImpersonatorID != 0 {
+		return nil, ErrImpersonationForbidden
+	}
+	credential, err := s.mfaRepo.GetCredential(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if credential.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.checkAccountCode(actor, credential, code); err != nil {
+		return nil, err
+	}
+
+	return s.confirmCredential(actor, credential)
+}
+
+func (s *userService) DisableMFA(actor domain.Actor, code string) error {
+	if actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+	if err := s.requireSecondFactor(actor, code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.DeleteCredential(actor.UserID); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditMFADisabled, actor.UserID, "")
+}
+
+func (s *userService) RegenerateRecoveryCodes(actor domain.Actor, code string) ([]string, error) {
+	if actor.ImpersonatorID != 0 {
+		return nil, ErrImpersonationForbidden
+	}
+	if err := s.requireSecondFactor(actor, code); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditRecoveryCodesReset, actor.UserID, ""); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetMFA removes a user's second factor, for when both the device and
+// the recovery codes are lost.
+func (s *userService) ResetMFA(actor domain.Actor, userID uint) error {
+	if _, err := s.GetUserByID(userID); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.DeleteCredential(userID); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditMFAReset, userID, "")
+}
+
+func (s *userService) GetMFAPolicy() (*domain.MFAPolicy, error) {
+	roles, err := s.mfaRepo.ListRequiredRoles()
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MFAPolicy{Roles: roles}, nil
+}
+
+// SetMFAPolicy replaces the roles that must use MFA. An empty list requires
+// it of nobody.
+func (s *userService) SetMFAPolicy(actor domain.Actor, policy *domain.MFAPolicy) error {
+	if len(policy.Roles) > 0 {
+		if err := validateRoles(policy.Roles); err != nil {
+			return err
+		}
+	}
+	if err := s.mfaRepo.SetRequiredRoles(policy.Roles); err != nil {
+		return err
+	}
+
+	roles := make([]string, 0, len(policy.Roles))
+	for _, role := range policy.Roles {
+		roles = append(roles, string(role))
+	}
+	return s.audit.Record(actor, domain.AuditMFAPolicyUpdated, 0, strings.Join(roles, ","))
+}
+
+func (s *userService) activeChallenge(token string) (*domain.MFAChallenge, error) {
+	challenge, err := s.mfaRepo.GetChallengeByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil ||
+		challenge.UsedAt != nil ||
+		challenge.Attempts >= mfaMaxAttempts ||
+		time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+	return challenge, nil
+}
+
+func (s *userService) requireSecondFactor(actor domain.Actor, code string) error {
+	credential, err := s.mfaRepo.GetCredential(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !credential.Confirmed() {
+		return ErrMFANotEnrolled
+	}
+	return s.checkAccountCode(actor, credential, code)
+}
+
+// checkAccountCode checks a code the signed-in user gave to change their
+// second factor. Wrong codes count towards the sign-in throttle as they do
+// in VerifyMFA, so a stolen access token cannot be used to guess them.
+func (s *userService) checkAccountCode(actor domain.Actor, credential *domain.MFACredential, code string) error {
+	user, err := s.GetUserByID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	client := domain.ClientInfo{IP: actor.IP, UserAgent: actor.UserAgent}
+	if err := s.checkThrottle(user.Username, client.IP); err != nil {
+		return err
+	}
+
+	ok, err := s.checkSecondFactor(credential, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.recordFailure(user.Username, user.ID, client, domain.LoginFailureInvalidMFACode); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// checkSecondFactor accepts a current TOTP code, or a recovery code once
+// the credential is confirmed. Each code is accepted only once.
+func (s *userService) checkSecondFactor(credential *domain.MFACredential, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(credential.Secret, code, time.Now(), mfaClockSkew); ok {
+		return s.mfaRepo.MarkStepUsed(credential.ID, step)
+	}
+
+	if !credential.Confirmed() {
+		return false, nil
+	}
+	return s.mfaRepo.UseRecoveryCode(credential.UserID, hashToken(normalizeRecoveryCode(code)))
+}
+
+func (s *userService) confirmCredential(actor domain.Actor, credential *domain.MFACredential) ([]string, error) {
+	now := time.Now()
+	credential.ConfirmedAt = &now
+	if err := s.mfaRepo.SaveCredential(credential); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditMFAEnabled, credential.UserID, ""); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(credential.UserID)
+}
+
+func (s *userService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 2*recoveryCodeHalfSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf[:recoveryCodeHalfSize]) +
+			"-" + recoveryCodeEncoding.EncodeToString(buf[recoveryCodeHalfSize:]))
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
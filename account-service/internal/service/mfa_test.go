@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/totp"
+)
+
+func TestMFAChangesAreThrottled(t *testing.T) {
+	ts := newTestUserService(t)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed := time.Now()
+	ts.mfa.SaveCredential(&domain.MFACredential{UserID: user.ID, Secret: secret, ConfirmedAt: &confirmed})
+	actor := domain.Actor{UserID: user.ID, IP: "10.0.0.1"}
+
+	var throttled *ThrottledError
+	for i := 0; !errors.As(err, &throttled); i++ {
+		if i > loginLockoutThreshold {
+			t.Fatalf("still guessing after %d wrong codes", i)
+		}
+		if err = ts.DisableMFA(actor, "000000"); err != ErrInvalidMFACode && !errors.As(err, &throttled) {
+			t.Fatalf("disable with a wrong code: %v", err)
+		}
+	}
+
+	// Once throttled, even the right code is refused.
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	if _, err := ts.RegenerateRecoveryCodes(actor, code); !errors.As(err, &throttled) {
+		t.Errorf("regenerate recovery codes while throttled: got %v, want a throttle", err)
+	}
+	if credential, _ := ts.mfa.GetCredential(user.ID); !credential.Confirmed() {
+		t.Error("MFA was disabled")
+	}
+}
+
+func TestMFAAdministrationChecksInput(t *testing.T) {
+	ts := newTestUserService(t)
+
+	if err := ts.SetMFAPolicy(domain.Actor{}, &domain.MFAPolicy{Roles: []domain.Role{"Janitor"}}); err != ErrInvalidRole {
+		t.Errorf("policy with an unknown role: got %v, want %v", err, ErrInvalidRole)
+	}
+	if err := ts.SetMFAPolicy(domain.Actor{}, &domain.MFAPolicy{}); err != nil {
+		t.Errorf("empty policy: %v", err)
+	}
+	if err := ts.ResetMFA(domain.Actor{}, 42); err != ErrUserNotFound {
+		t.Errorf("reset MFA of a missing account: got %v, want %v", err, ErrUserNotFound)
+	}
+	if len(ts.audit.events) != 1 {
+		t.Errorf("audited %d events, want the empty policy alone", len(ts.audit.events))
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrTooManyResetRequests is returned by RequestReset when the client
+	// address has asked for too many resets recently.
+	ErrTooManyResetRequests = errors.New("too many password reset requests, try again later")
+)
+
+const (
+	passwordResetTTL         = time.Hour
+	passwordResetSendTimeout = 30 * time.Second
+
+	// Resets are limited per address and per account over the window. An
+	// account over its limit is skipped silently, like one that does not
+	// exist, so the limit cannot be used to probe for accounts.
+	passwordResetWindow      = time.Hour
+	passwordResetsPerIP      = 10
+	passwordResetsPerAccount = 3
+)
+
+type PasswordResetService interface {
+	RequestReset(req *domain.PasswordResetRequest, client domain.ClientInfo) error
+	ConfirmReset(req *domain.PasswordResetConfirmRequest, client domain.ClientInfo) error
+}
+
+type passwordResetService struct {
+	userRepo    repository.UserRepository
+	resetRepo   repository.PasswordResetRepository
+	loginRepo   repository.LoginAttemptRepository
+	userService UserService
+	passwords   *Passwords
+	audit       AuditService
+	notifier    notify.Notifier
+	resetURL    string
+}
+
+// NewPasswordResetService creates the reset flow. The token is appended to
+// resetURL to build the link sent to the user.
+func NewPasswordResetService(
+	userRepo repository.UserRepository,
+	resetRepo repository.PasswordResetRepository,
+	loginRepo repository.LoginAttemptRepository,
+	userService UserService,
+	passwords *Passwords,
+	audit AuditService,
+	notifier notify.Notifier,
+	resetURL string,
+) PasswordResetService {
+	return &passwordResetService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		loginRepo:   loginRepo,
+		userService: userService,
+		passwords:   passwords,
+		audit:       audit,
+		notifier:    notifier,
+		resetURL:    resetURL,
+	}
+}
+
+// RequestReset sends a reset link if the account exists and has an email
+// address. It reports success either way so callers cannot probe which
+// accounts exist.
+func (s *passwordResetService) RequestReset(req *domain.PasswordResetRequest, client domain.ClientInfo) error {
+	since := time.Now().Add(-passwordResetWindow)
+	attempts, err := s.resetRepo.CountAttemptsSince(client.IP, since)
+	if err != nil {
+		return err
+	}
+	if attempts >= passwordResetsPerIP {
+		return ErrTooManyResetRequests
+	}
+	if err := s.resetRepo.RecordAttempt(client.IP); err != nil {
+		return err
+	}
+
+	var user *domain.User
+	switch {
+	case req.Username != "":
+		user, err = s.userRepo.GetByUsername(req.Username)
+	case req.Email != "":
+		user, err = s.userRepo.GetByEmail(req.Email)
+	}
+	if err != nil {
+		return err
+	}
+	// Directory accounts reset their password in the directory.
+	if user == nil || user.Email == "" || user.Source != domain.UserSourceLocal {
+		return nil
+	}
+	issued, err := s.resetRepo.CountSince(user.ID, since)
+	if err != nil {
+		return err
+	}
+	if issued >= passwordResetsPerAccount {
+		return nil
+	}
+
+	// Only the newest link works.
+	if err := s.resetRepo.InvalidateAllForUser(user.ID); err != nil {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.resetRepo.Create(&domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s%s\n\nIf you did not ask for this, ignore this message.",
+			user.FirstName, passwordResetTTL, s.resetURL, token,
+		),
+	}
+
+	// Sending in the background keeps the response time the same whether
+	// or not the account exists.
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+		defer cancel()
+		if err := s.notifier.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ConfirmReset sets the new password and signs the user out everywhere, as
+// whoever knew the old password should not keep their sessions.
+func (s *passwordResetService) ConfirmReset(req *domain.PasswordResetConfirmRequest, client domain.ClientInfo) error {
+	token, err := s.resetRepo.GetByHash(hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Source != domain.UserSourceLocal {
+		return ErrInvalidResetToken
+	}
+
+	// Check the new password before consuming the token, so a rejected
+	// password can be corrected with the same link. Nothing is written
+	// until the token is claimed, so of two concurrent confirmations only
+	// one sets a password.
+	if err := s.passwords.Validate(user, req.Password); err != nil {
+		return err
+	}
+
+	used, err := s.resetRepo.MarkUsed(token.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.passwords.Set(user, req.Password); err != nil {
+		return err
+	}
+	user.MustChangePassword = false
+
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	if err := s.loginRepo.ClearFailures(user.Username); err != nil {
+		return err
+	}
+	actor := client.As(user.ID)
+	if err := s.audit.Record(actor, domain.AuditPasswordReset, user.ID, ""); err != nil {
+		return err
+	}
+	return s.userService.RevokeUserTokens(actor, user.ID)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ValidationError lists what is wrong with each invalid field of a request.
+type ValidationError struct {
+	Fields map[string][]string
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for field, problems := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s %s", field, strings.Join(problems, ", ")))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Passwords is the single place new passwords are hashed, so the policy
+// applies to every way a password can be set.
+type Passwords struct {
+	policy  *password.Policy
+	history repository.PasswordHistoryRepository
+}
+
+func NewPasswords(policy *password.Policy, history repository.PasswordHistoryRepository) *Passwords {
+	return &Passwords{
+		policy:  policy,
+		history: history,
+	}
+}
+
+// Check tells whether plain is acceptable as a first password for the
+// username, without hashing it.
+func (p *Passwords) Check(username, plain string) error {
+	if violations := p.policy.Check(plain, username); len(violations) > 0 {
+		return passwordError(violations...)
+	}
+	return nil
+}
+
+// Generate returns a random temporary password that meets the policy.
+func (p *Passwords) Generate(username string) (string, error) {
+	length := p.policy.MinLength
+	if length < generatedPasswordLength {
+		length = generatedPasswordLength
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		// One character of every class first, so any MinClasses is met.
+		buf := make([]rune, 0, length)
+		for _, set := range generatedPasswordClasses {
+			r, err := randomRune(set)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, r)
+		}
+		for len(buf) < length {
+			r, err := randomRune(generatedPasswordAlphabet)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, r)
+		}
+		for i := len(buf) - 1; i > 0; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+			buf[i], buf[j.Int64()] = buf[j.Int64()], buf[i]
+		}
+
+		plain := string(buf)
+		if len(p.policy.Check(plain, username)) == 0 {
+			return plain, nil
+		}
+	}
+	return "", errors.New("could not generate a password that meets the policy")
+}
+
+// Validate checks plain against the policy and the user's recent passwords
+// without changing anything.
+func (p *Passwords) Validate(user *domain.User, plain string) error {
+	if violations := p.policy.Check(plain, user.Username); len(violations) > 0 {
+		return passwordError(violations...)
+	}
+
+	if user.Password != "" {
+		reused, err := p.reused(user, plain)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return passwordError(fmt.Sprintf("must differ from the last %d passwords", p.policy.HistorySize))
+		}
+	}
+	return nil
+}
+
+// Set checks plain against the policy and the user's recent passwords and
+// stores its hash on the user. The caller saves the user.
+func (p *Passwords) Set(user *domain.User, plain string) error {
+	if err := p.Validate(user, plain); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" && user.ID != 0 && p.policy.HistorySize > 0 {
+		if err := p.history.Create(&domain.PasswordHistory{
+			UserID:       user.ID,
+			PasswordHash: user.Password,
+		}); err != nil {
+			return err
+		}
+	}
+
+	user.Password = string(hashed)
+	return nil
+}
+
+// reused reports whether plain matches the current password or one of the
+// previous ones still covered by the history size.
+func (p *Passwords) reused(user *domain.User, plain string) (bool, error) {
+	if p.policy.HistorySize <= 0 {
+		return false, nil
+	}
+
+	hashes := []string{user.Password}
+	if user.ID != 0 && p.policy.HistorySize > 1 {
+		entries, err := p.history.ListRecent(user.ID, p.policy.HistorySize-1)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const generatedPasswordLength = 16
+
+// generatedPasswordClasses leaves out characters that are easy to misread
+// when a temporary password is passed on by hand.
+var generatedPasswordClasses = []string{
+	"abcdefghijkmnopqrstuvwxyz",
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"23456789",
+	"!#%+-=?@",
+}
+
+var generatedPasswordAlphabet = strings.Join(generatedPasswordClasses, "")
+
+func randomRune(set string) (rune, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return rune(set[n.Int64()]), nil
+}
+
+func passwordError(problems ...string) error {
+	return &ValidationError{Fields: map[string][]string{"password": problems}}
+}
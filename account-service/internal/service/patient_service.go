@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+)
+
+var (
+	ErrPatientProfileNotFound = errors.New("patient profile not found")
+	ErrNotPatient             = errors.New("only accounts with the User role can have a patient profile")
+)
+
+type PatientService interface {
+	GetProfile(userID uint) (*domain.PatientProfile, error)
+	SaveProfile(actor domain.Actor, userID uint, req *domain.PatientProfileRequest) (*domain.PatientProfile, error)
+	DeleteProfile(actor domain.Actor, userID uint) error
+}
+
+type patientService struct {
+	repo     repository.PatientRepository
+	userRepo repository.UserRepository
+	audit    AuditService
+}
+
+func NewPatientService(repo repository.PatientRepository, userRepo repository.UserRepository, audit AuditService) PatientService {
+	return &patientService{
+		repo:     repo,
+		userRepo: userRepo,
+		audit:    audit,
+	}
+}
+
+func (s *patientService) GetProfile(userID uint) (*domain.PatientProfile, error) {
+	profile, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, ErrPatientProfileNotFound
+	}
+	return profile, nil
+}
+
+// SaveProfile creates the user's profile or replaces it as a whole. Only
+// accounts with the User role can have one.
+func (s *patientService) SaveProfile(actor domain.Actor, userID uint, req *domain.PatientProfileRequest) (*domain.PatientProfile, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.HasRole(domain.RoleUser) {
+		return nil, ErrNotPatient
+	}
+
+	now := time.Now()
+	if req.DateOfBirth != nil && req.DateOfBirth.After(now) {
+		return nil, &ValidationError{Fields: map[string][]string{
+			"date_of_birth": {"must not be in the future"},
+		}}
+	}
+
+	profile, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &domain.PatientProfile{UserID: userID}
+	}
+
+	if profile.ConsentTreatment != req.ConsentTreatment ||
+		profile.ConsentDataSharing != req.ConsentDataSharing ||
+		profile.ConsentUpdatedAt == nil {
+		profile.ConsentUpdatedAt = &now
+	}
+
+	profile.DateOfBirth = req.DateOfBirth
+	profile.Gender = req.Gender
+	profile.Phone = req.Phone
+	profile.Address = req.Address
+	profile.EmergencyContactName = req.EmergencyContactName
+	profile.EmergencyContactPhone = req.EmergencyContactPhone
+	profile.EmergencyContactRelation = req.EmergencyContactRelation
+	profile.InsuranceProvider = req.InsuranceProvider
+	profile.InsurancePolicyNumber = req.InsurancePolicyNumber
+	profile.InsuranceValidUntil = req.InsuranceValidUntil
+	profile.ConsentTreatment = req.ConsentTreatment
+	profile.ConsentDataSharing = req.ConsentDataSharing
+
+	if err := s.repo.Save(profile); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditPatientProfileUpdated, userID, ""); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *patientService) DeleteProfile(actor domain.Actor, userID uint) error {
+	deleted, err := s.repo.DeleteByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPatientProfileNotFound
+	}
+	return s.audit.Record(actor, domain.AuditPatientProfileDeleted, userID, "")
+}
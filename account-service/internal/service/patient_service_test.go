@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func TestPatientProfilesAreForPatientsOnly(t *testing.T) {
+	ts := newTestUserService(t)
+	patients := &fakePatients{}
+	svc := NewPatientService(patients, ts.users, ts.audit)
+	patient := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+	doctor := ts.addUser(t, "dr.house", "Correct-Horse-42", domain.RoleDoctor)
+
+	if _, err := svc.SaveProfile(domain.Actor{UserID: patient.ID}, patient.ID, &domain.PatientProfileRequest{Phone: "555"}); err != nil {
+		t.Errorf("save a patient's profile: %v", err)
+	}
+	if _, err := svc.SaveProfile(domain.Actor{UserID: doctor.ID}, doctor.ID, &domain.PatientProfileRequest{Phone: "555"}); err != ErrNotPatient {
+		t.Errorf("save a doctor's profile: got %v, want %v", err, ErrNotPatient)
+	}
+	if len(patients.profiles) != 1 {
+		t.Errorf("stored %d profiles, want 1", len(patients.profiles))
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
+)
+
+var (
+	// ErrVerificationRequired is returned by SignIn for accounts whose email
+	// address has not been confirmed yet.
+	ErrVerificationRequired = errors.New("account is not verified")
+	// ErrApprovalPending is returned by SignIn for accounts an admin has not
+	// approved yet.
+	ErrApprovalPending = errors.New("account is awaiting approval")
+	// ErrAccountDisabled is returned by SignIn for accounts an admin or a
+	// provisioning client has disabled.
+	ErrAccountDisabled         = errors.New("account is disabled")
+	ErrInvalidVerificationCode = errors.New("invalid or expired verification code")
+	ErrAccountNotPending       = errors.New("account is not pending")
+)
+
+const (
+	verificationCodeTTL        = 15 * time.Minute
+	verificationMaxAttempts    = 5
+	verificationResendInterval = time.Minute
+	verificationSendTimeout    = 30 * time.Second
+
+	// verificationMaxCodes caps the codes sent to one account, and with
+	// verificationMaxAttempts the guesses at them. An account that runs out
+	// has to be approved by an admin or left to expire.
+	verificationMaxCodes = 5
+)
+
+// SignUp registers a patient account. Depending on the registration mode it
+// is active right away, waits for the code sent to its email address, or
+// waits for an admin.
+func (s *userService) SignUp(req *domain.SignUpRequest, client domain.ClientInfo) (*domain.SignUpResponse, error) {
+	if s.registration == domain.RegistrationVerify && req.Email == "" {
+		return nil, &ValidationError{Fields: map[string][]string{
+			"email": {"is required to verify the account"},
+		}}
+	}
+
+	existingUser, err := s.repo.GetByUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, ErrUserAlreadyExists
+	}
+
+	user := &domain.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Roles:     []domain.Role{domain.RoleUser},
+		Status:    domain.UserActive,
+		Source:    domain.UserSourceLocal,
+	}
+	switch s.registration {
+	case domain.RegistrationVerify:
+		user.Status = domain.UserPendingVerification
+	case domain.RegistrationApproval:
+		user.Status = domain.UserPendingApproval
+	}
+	if err := s.passwords.Set(user, req.Password); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(client.As(user.ID), domain.AuditSignUp, user.ID, string(user.Status)); err != nil {
+		return nil, err
+	}
+
+	if user.Status == domain.UserPendingVerification {
+		if err := s.sendVerificationCode(user); err != nil {
+			return nil, err
+		}
+	}
+	return &domain.SignUpResponse{Status: user.Status}, nil
+}
+
+// VerifyAccount activates an account with the code sent to its owner. Every
+// wrong guess counts against the code, which stops working after a few.
+func (s *userService) VerifyAccount(req *domain.VerifyAccountRequest, client domain.ClientInfo) error {
+	user, err := s.repo.GetByUsername(req.Username)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != domain.UserPendingVerification {
+		return ErrInvalidVerificationCode
+	}
+
+	code, err := s.verifyRepo.GetLatest(user.ID)
+	if err != nil {
+		return err
+	}
+	if code == nil ||
+		code.Attempts >= verificationMaxAttempts ||
+		time.Now().After(code.ExpiresAt) {
+		return ErrInvalidVerificationCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(strings.TrimSpace(req.Code))), []byte(code.CodeHash)) != 1 {
+		if err := s.verifyRepo.IncrementAttempts(code.ID); err != nil {
+			return err
+		}
+		return ErrInvalidVerificationCode
+	}
+
+	used, err := s.verifyRepo.MarkUsed(code.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidVerificationCode
+	}
+
+	user.Status = domain.UserActive
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	return s.audit.Record(client.As(user.ID), domain.AuditAccountVerified, user.ID, "")
+}
+
+// ResendVerification sends a new code, replacing the previous one, at most
+// once a minute and verificationMaxCodes times in all. It reports success
+// whether or not the account exists, needs verifying or has codes left, so
+// callers cannot probe for accounts.
+func (s *userService) ResendVerification(req *domain.ResendVerificationRequest) error {
+	user, err := s.repo.GetByUsername(req.Username)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != domain.UserPendingVerification {
+		return nil
+	}
+
+	latest, err := s.verifyRepo.GetLatest(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < verificationResendInterval {
+		return nil
+	}
+	sent, err := s.verifyRepo.CountForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	if sent >= verificationMaxCodes {
+		return nil
+	}
+	return s.sendVerificationCode(user)
+}
+
+// ExpirePendingUsers deletes the accounts still pending verification or
+// approval pendingTTL after they signed up, which frees their usernames.
+// Like any deleted account they can be restored until they are purged. It
+// reports how many it deleted.
+func (s *userService) ExpirePendingUsers(actor domain.Actor) (int, error) {
+	if s.pendingTTL <= 0 {
+		return 0, nil
+	}
+
+	users, err := s.repo.DeletePendingBefore(time.Now().Add(-s.pendingTTL))
+	if err != nil {
+		return 0, err
+	}
+	for _, user := range users {
+		if err := s.verifyRepo.InvalidateAllForUser(user.ID); err != nil {
+			return 0, err
+		}
+		if err := s.audit.Record(actor, domain.AuditAccountExpired, user.ID, string(user.Status)); err != nil {
+			return 0, err
+		}
+	}
+	return len(users), nil
+}
+
+// ApproveUser activates a pending account. Admins can use it for accounts
+// waiting on email verification too, when the code does not arrive.
+func (s *userService) ApproveUser(actor domain.Actor, id uint) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if user.Status != domain.UserPendingVerification && user.Status != domain.UserPendingApproval {
+		return ErrAccountNotPending
+	}
+
+	user.Status = domain.UserActive
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.verifyRepo.InvalidateAllForUser(user.ID); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditAccountApproved, user.ID, "")
+}
+
+// SetUserActive enables or disables an account. Disabling ends its
+// sessions and keeps it from signing in until it is enabled again; enabling
+// also activates an account that is still pending.
+func (s *userService) SetUserActive(actor domain.Actor, id uint, active bool) error {
+	if actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if (user.Status == domain.UserActive) == active {
+		return nil
+	}
+	if !active {
+		if err := s.ensureAnotherAdmin(user); err != nil {
+			return err
+		}
+	}
+
+	action := domain.AuditAccountEnabled
+	user.Status = domain.UserActive
+	if !active {
+		action = domain.AuditAccountDisabled
+		user.Status = domain.UserDisabled
+	}
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.verifyRepo.InvalidateAllForUser(user.ID); err != nil {
+		return err
+	}
+	if err := s.audit.Record(actor, action, user.ID, ""); err != nil {
+		return err
+	}
+	if !active {
+		return s.revokeUserTokens(user.ID)
+	}
+	return nil
+}
+
+func (s *userService) sendVerificationCode(user *domain.User) error {
+	if err := s.verifyRepo.InvalidateAllForUser(user.ID); err != nil {
+		return err
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	if err := s.verifyRepo.Create(&domain.VerificationCode{
+		UserID:    user.ID,
+		CodeHash:  hashToken(code),
+		ExpiresAt: time.Now().Add(verificationCodeTTL),
+	}); err != nil {
+		return err
+	}
+
+	msg := notify.Message{
+		To:      user.Email,
+		Subject: "Verify your account",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nYour verification code is %s. It expires in %s.\n\nIf you did not sign up, ignore this message.",
+			user.FirstName, code, verificationCodeTTL,
+		),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), verificationSendTimeout)
+		defer cancel()
+		if err := s.notifier.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send verification code to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
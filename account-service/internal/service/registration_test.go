@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func signUp(t *testing.T, ts *testUserService, username string) {
+	t.Helper()
+
+	if _, err := ts.SignUp(&domain.SignUpRequest{
+		Username:  username,
+		Email:     username + "@example.org",
+		Password:  "Correct-Horse-42",
+		FirstName: "Test",
+		LastName:  "User",
+	}, domain.ClientInfo{}); err != nil {
+		t.Fatalf("sign up %s: %v", username, err)
+	}
+}
+
+func TestResendVerificationIsCapped(t *testing.T) {
+	ts := newTestUserService(t)
+	ts.registration = domain.RegistrationVerify
+	signUp(t, ts, "jsmith")
+
+	for i := 0; i < 2*verificationMaxCodes; i++ {
+		// Step past the resend interval.
+		for _, code := range ts.verify.codes {
+			code.CreatedAt = code.CreatedAt.Add(-verificationResendInterval)
+		}
+		if err := ts.ResendVerification(&domain.ResendVerificationRequest{Username: "jsmith"}); err != nil {
+			t.Fatalf("resend: %v", err)
+		}
+	}
+
+	if sent := len(ts.verify.codes); sent != verificationMaxCodes {
+		t.Errorf("sent %d codes, want %d", sent, verificationMaxCodes)
+	}
+}
+
+func TestExpirePendingUsersFreesUsernames(t *testing.T) {
+	ts := newTestUserService(t)
+	ts.registration = domain.RegistrationApproval
+	signUp(t, ts, "stale")
+	signUp(t, ts, "fresh")
+	ts.addUser(t, "active", "Correct-Horse-42", domain.RoleUser)
+	for _, user := range ts.users.users {
+		if user.Username != "fresh" {
+			user.CreatedAt = user.CreatedAt.Add(-pendingAccountTTL - time.Minute)
+		}
+	}
+
+	expired, err := ts.ExpirePendingUsers(domain.Actor{})
+	if err != nil {
+		t.Fatalf("expire: %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("expired %d accounts, want 1", expired)
+	}
+	for username, want := range map[string]bool{"stale": false, "fresh": true, "active": true} {
+		user, _ := ts.users.GetByUsername(username)
+		if got := user != nil; got != want {
+			t.Errorf("%s kept = %v, want %v", username, got, want)
+		}
+	}
+
+	signUp(t, ts, "stale")
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrLastAdmin   = errors.New("the last admin cannot lose the Admin role")
+)
+
+// GrantRole adds a role to the user. Their current tokens are revoked so the
+// new role applies from their next sign-in.
+func (s *userService) GrantRole(actor domain.Actor, id uint, role domain.Role) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if user.HasRole(role) {
+		return nil
+	}
+
+	return s.setRoles(actor, user, append(append([]domain.Role{}, user.Roles...), role))
+}
+
+// RevokeRole removes a role from the user and revokes their tokens.
+func (s *userService) RevokeRole(actor domain.Actor, id uint, role domain.Role) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if !user.HasRole(role) {
+		return nil
+	}
+
+	roles := make([]domain.Role, 0, len(user.Roles))
+	for _, have := range user.Roles {
+		if have != role {
+			roles = append(roles, have)
+		}
+	}
+	return s.setRoles(actor, user, roles)
+}
+
+// setRoles replaces the user's roles, refusing to take Admin away from the
+// last account that holds it. Every role gained or lost is audited.
+func (s *userService) setRoles(actor domain.Actor, user *domain.User, roles []domain.Role) error {
+	if actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+	if err := validateRoles(roles); err != nil {
+		return err
+	}
+
+	updated := &domain.User{Roles: roles}
+	if !updated.HasRole(domain.RoleAdmin) {
+		if err := s.ensureAnotherAdmin(user); err != nil {
+			return err
+		}
+	}
+
+	previous := user.Roles
+	user.Roles = roles
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if err := s.recordRoleChanges(actor, user.ID, previous, roles); err != nil {
+		return err
+	}
+	return s.revokeUserTokens(user.ID)
+}
+
+// recordRoleChanges audits each role gained or lost going from previous to
+// roles.
+func (s *userService) recordRoleChanges(actor domain.Actor, userID uint, previous, roles []domain.Role) error {
+	before := &domain.User{Roles: previous}
+	after := &domain.User{Roles: roles}
+	for _, role := range roles {
+		if !before.HasRole(role) {
+			if err := s.audit.Record(actor, domain.AuditRoleGranted, userID, string(role)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, role := range previous {
+		if !after.HasRole(role) {
+			if err := s.audit.Record(actor, domain.AuditRoleRevoked, userID, string(role)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ensureAnotherAdmin refuses to let the user stop being an active admin
+// when no other active admin is left. Disabled and pending admins cannot
+// sign in, so they do not count.
+func (s *userService) ensureAnotherAdmin(user *domain.User) error {
+	if user.Status != domain.UserActive || !user.HasRole(domain.RoleAdmin) {
+		return nil
+	}
+	admins, err := s.repo.CountByRole(domain.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func validateRoles(roles []domain.Role) error {
+	if len(roles) == 0 {
+		return ErrInvalidRole
+	}
+	for _, role := range roles {
+		if !role.Valid() {
+			return ErrInvalidRole
+		}
+	}
+	return nil
+}
+
+func rolesEqual(a, b []domain.Role) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[domain.Role]bool, len(a))
+	for _, role := range a {
+		set[role] = true
+	}
+	for _, role := range b {
+		if !set[role] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func TestLastActiveAdminIsKept(t *testing.T) {
+	ts := newTestUserService(t)
+	admin := ts.addUser(t, "admin", "Correct-Horse-42", domain.RoleAdmin, domain.RoleUser)
+	disabled := ts.addUser(t, "former", "Correct-Horse-42", domain.RoleAdmin)
+	if err := ts.SetUserActive(domain.Actor{UserID: admin.ID}, disabled.ID, false); err != nil {
+		t.Fatalf("disable the second admin: %v", err)
+	}
+
+	actor := domain.Actor{UserID: admin.ID}
+	if err := ts.SetUserActive(actor, admin.ID, false); err != ErrLastAdmin {
+		t.Errorf("disable the last active admin: got %v, want %v", err, ErrLastAdmin)
+	}
+	if err := ts.RevokeRole(actor, admin.ID, domain.RoleAdmin); err != ErrLastAdmin {
+		t.Errorf("revoke Admin from the last active admin: got %v, want %v", err, ErrLastAdmin)
+	}
+
+	// A disabled admin is not one of the admins that must be kept.
+	if err := ts.RevokeRole(actor, disabled.ID, domain.RoleAdmin); err != ErrInvalidRole {
+		t.Errorf("revoke the only role of a disabled admin: got %v, want %v", err, ErrInvalidRole)
+	}
+	if err := ts.GrantRole(actor, disabled.ID, domain.RoleUser); err != nil {
+		t.Fatalf("grant User: %v", err)
+	}
+	if err := ts.RevokeRole(actor, disabled.ID, domain.RoleAdmin); err != nil {
+		t.Errorf("revoke Admin from a disabled admin: %v", err)
+	}
+}
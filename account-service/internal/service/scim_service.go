@@ -0,0 +1,869 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
+	"github.com/sergeimurashev/hospital-system-api/account-service/pkg/scim"
+)
+
+// SCIM errors carry a detail after the sentinel; compare them with
+// errors.Is.
+var (
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrSCIMInvalidValue = errors.New("invalid value")
+	ErrSCIMMutability   = errors.New("attribute cannot be changed")
+	// ErrSCIMAdminAccount is returned for changes to an account holding
+	// Admin, which only admins may make.
+	ErrSCIMAdminAccount = errors.New("admin accounts cannot be changed through SCIM")
+)
+
+const scimMaxCount = 200
+
+// scimGroupRoles are the roles served as SCIM Groups. Admin is left out:
+// a provisioning client must not be able to make or unmake admins.
+var scimGroupRoles = []domain.Role{domain.RoleManager, domain.RoleDoctor, domain.RoleUser}
+
+// SCIMService provisions accounts for SCIM 2.0 clients. Users are accounts
+// of any status; Groups are the fixed set of roles, so groups can only have
+// their members changed. Accounts holding Admin can be read but not
+// changed, or a client could take one over by setting its password.
+type SCIMService interface {
+	ListUsers(filter string, startIndex, count int) (*domain.SCIMListResponse, error)
+	GetUser(id uint) (*domain.SCIMUser, error)
+	CreateUser(actor domain.Actor, user *domain.SCIMUser) (*domain.SCIMUser, error)
+	ReplaceUser(actor domain.Actor, id uint, user *domain.SCIMUser) (*domain.SCIMUser, error)
+	PatchUser(actor domain.Actor, id uint, req *domain.SCIMPatchRequest) (*domain.SCIMUser, error)
+	DeleteUser(actor domain.Actor, id uint) error
+	ListGroups(filter string, startIndex, count int, withMembers bool) (*domain.SCIMListResponse, error)
+	GetGroup(id string, withMembers bool) (*domain.SCIMGroup, error)
+	ReplaceGroup(actor domain.Actor, id string, group *domain.SCIMGroup) (*domain.SCIMGroup, error)
+	PatchGroup(actor domain.Actor, id string, req *domain.SCIMPatchRequest) error
+}
+
+type scimService struct {
+	repo      repository.UserRepository
+	users     UserService
+	passwords *Passwords
+	audit     AuditService
+}
+
+func NewSCIMService(repo repository.UserRepository, users UserService, passwords *Passwords, audit AuditService) SCIMService {
+	return &scimService{
+		repo:      repo,
+		users:     users,
+		passwords: passwords,
+		audit:     audit,
+	}
+}
+
+// scimUserChange is the state a PUT or PATCH asks an account to end up in.
+// An empty password and a nil active leave those alone.
+type scimUserChange struct {
+	user     domain.User
+	password string
+	active   *bool
+}
+
+func (s *scimService) ListUsers(filter string, startIndex, count int) (*domain.SCIMListResponse, error) {
+	var expr scim.Expr
+	if filter != "" {
+		var err error
+		if expr, err = scim.Parse(filter); err != nil {
+			return nil, err
+		}
+	}
+	startIndex, count = scimPage(startIndex, count)
+
+	users, total, err := s.repo.ListSCIM(expr, startIndex-1, count)
+	if err != nil {
+		return nil, err
+	}
+
+	list := newSCIMList(total, startIndex)
+	for i := range users {
+		list.Resources = append(list.Resources, toSCIMUser(&users[i]))
+	}
+	list.ItemsPerPage = len(list.Resources)
+	return list, nil
+}
+
+func (s *scimService) GetUser(id uint) (*domain.SCIMUser, error) {
+	user, err := s.users.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user), nil
+}
+
+// CreateUser creates an account from a SCIM User. Without a password the
+// account gets a random one nobody knows; its owner sets their own through
+// a password reset.
+func (s *scimService) CreateUser(actor domain.Actor, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+	change, err := fromSCIMUser(in, &domain.User{Roles: []domain.Role{domain.RoleUser}})
+	if err != nil {
+		return nil, err
+	}
+	user := &change.user
+	if err := s.validate(user, ""); err != nil {
+		return nil, err
+	}
+
+	user.Status = domain.UserActive
+	user.Source = domain.UserSourceLocal
+	if change.active != nil && !*change.active {
+		user.Status = domain.UserDisabled
+	}
+	plain := change.password
+	if plain == "" {
+		if plain, err = s.passwords.Generate(user.Username); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.passwords.Set(user, plain); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(actor, domain.AuditAccountCreated, user.ID, ""); err != nil {
+		return nil, err
+	}
+	for _, role := range user.Roles {
+		if err := s.audit.Record(actor, domain.AuditRoleGranted, user.ID, string(role)); err != nil {
+			return nil, err
+		}
+	}
+	return toSCIMUser(user), nil
+}
+
+// ReplaceUser applies a full User resource. Roles are kept when the
+// resource has none, since most clients manage them through Groups.
+func (s *scimService) ReplaceUser(actor domain.Actor, id uint, in *domain.SCIMUser) (*domain.SCIMUser, error) {
+	user, err := s.managedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	change, err := fromSCIMUser(in, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(actor, user, change)
+}
+
+func (s *scimService) PatchUser(actor domain.Actor, id uint, req *domain.SCIMPatchRequest) (*domain.SCIMUser, error) {
+	user, err := s.managedUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &scimUserChange{user: *user}
+	change.user.Roles = append([]domain.Role{}, user.Roles...)
+	for _, op := range req.Operations {
+		if err := patchUser(change, op); err != nil {
+			return nil, err
+		}
+	}
+	return s.apply(actor, user, change)
+}
+
+// DeleteUser soft-deletes the account, so an admin can still restore it
+// within the retention period.
+func (s *scimService) DeleteUser(actor domain.Actor, id uint) error {
+	if _, err := s.managedUser(id); err != nil {
+		return err
+	}
+	return s.users.DeleteUser(actor, id)
+}
+
+// managedUser returns the account if SCIM may change it.
+func (s *scimService) managedUser(id uint) (*domain.User, error) {
+	user, err := s.users.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasRole(domain.RoleAdmin) {
+		return nil, ErrSCIMAdminAccount
+	}
+	return user, nil
+}
+
+// apply saves the changed state in one go through SaveAccount, which audits
+// each change and revokes tokens where needed.
+func (s *scimService) apply(actor domain.Actor, user *domain.User, change *scimUserChange) (*domain.SCIMUser, error) {
+	next := &change.user
+	if err := s.validate(next, user.Username); err != nil {
+		return nil, err
+	}
+	// Names cannot be emptied, as with the REST API.
+	if next.FirstName == "" {
+		next.FirstName = user.FirstName
+	}
+	if next.LastName == "" {
+		next.LastName = user.LastName
+	}
+	// As with Enable and Disable, active true also activates a pending
+	// account, while false only disables an active one.
+	if change.active != nil && *change.active != (user.Status == domain.UserActive) {
+		next.Status = domain.UserDisabled
+		if *change.active {
+			next.Status = domain.UserActive
+		}
+	}
+
+	if err := s.users.SaveAccount(actor, next, change.password); err != nil {
+		return nil, err
+	}
+	return s.GetUser(user.ID)
+}
+
+// validate checks what the account operations do not: a username is given
+// and free, the email address is well formed, and a role is left.
+func (s *scimService) validate(user *domain.User, currentUsername string) error {
+	if user.Username == "" {
+		return fmt.Errorf("%w: userName is required", ErrSCIMInvalidValue)
+	}
+	if user.Username != currentUsername {
+		existing, err := s.repo.GetByUsername(user.Username)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrUserAlreadyExists
+		}
+	}
+	if user.Email != "" {
+		if address, err := mail.ParseAddress(user.Email); err != nil || address.Address != user.Email {
+			return fmt.Errorf("%w: %q is not a valid email address", ErrSCIMInvalidValue, user.Email)
+		}
+	}
+	if len(user.Roles) == 0 {
+		return fmt.Errorf("%w: an account needs at least one role", ErrSCIMInvalidValue)
+	}
+	return nil
+}
+
+func (s *scimService) ListGroups(filter string, startIndex, count int, withMembers bool) (*domain.SCIMListResponse, error) {
+	var expr scim.Expr
+	needMembers := withMembers
+	if filter != "" {
+		var err error
+		if expr, err = scim.Parse(filter); err != nil {
+			return nil, err
+		}
+		for _, attr := range scim.Attributes(expr) {
+			switch attr {
+			case "id", "displayname":
+			case "members", "members.value":
+				needMembers = true
+			default:
+				return nil, fmt.Errorf("%w: attribute %q cannot be filtered on", scim.ErrInvalidFilter, attr)
+			}
+		}
+	}
+	startIndex, count = scimPage(startIndex, count)
+
+	var groups []*domain.SCIMGroup
+	for _, role := range scimGroupRoles {
+		group, err := s.group(role, needMembers)
+		if err != nil {
+			return nil, err
+		}
+		if expr != nil && !scim.Match(expr, groupAttributes(group)) {
+			continue
+		}
+		if !withMembers {
+			group.Members = []domain.SCIMValue{}
+		}
+		groups = append(groups, group)
+	}
+
+	list := newSCIMList(int64(len(groups)), startIndex)
+	for i := startIndex - 1; i < len(groups) && len(list.Resources) < count; i++ {
+		list.Resources = append(list.Resources, groups[i])
+	}
+	list.ItemsPerPage = len(list.Resources)
+	return list, nil
+}
+
+func (s *scimService) GetGroup(id string, withMembers bool) (*domain.SCIMGroup, error) {
+	role, ok := scimRole(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	return s.group(role, withMembers)
+}
+
+// ReplaceGroup sets the members of a group. Its displayName is the role
+// name and cannot change.
+func (s *scimService) ReplaceGroup(actor domain.Actor, id string, in *domain.SCIMGroup) (*domain.SCIMGroup, error) {
+	role, ok := scimRole(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	if in.DisplayName != "" && !strings.EqualFold(in.DisplayName, string(role)) {
+		return nil, fmt.Errorf("%w: displayName of group %s", ErrSCIMMutability, role)
+	}
+
+	ids, err := memberIDs(in.Members)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setMembers(actor, role, ids); err != nil {
+		return nil, err
+	}
+	return s.group(role, true)
+}
+
+// PatchGroup adds and removes members. Removing the group from an account
+// that has no other role is refused, since every account needs one.
+func (s *scimService) PatchGroup(actor domain.Actor, id string, req *domain.SCIMPatchRequest) error {
+	role, ok := scimRole(id)
+	if !ok {
+		return ErrGroupNotFound
+	}
+
+	for _, op := range req.Operations {
+		if op.Path == "" {
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return fmt.Errorf("%w: a patch without a path takes an object", ErrSCIMInvalidValue)
+			}
+			for name, value := range values {
+				path, err := scim.ParsePath(name)
+				if err != nil {
+					return err
+				}
+				if err := s.patchGroup(actor, role, op.Op, path, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path, err := scim.ParsePath(op.Path)
+		if err != nil {
+			return err
+		}
+		if err := s.patchGroup(actor, role, op.Op, path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scimService) patchGroup(actor domain.Actor, role domain.Role, op string, path *scim.Path, value json.RawMessage) error {
+	op = strings.ToLower(op)
+	switch path.Attr {
+	case "displayname":
+		name, err := scimString(value)
+		if err != nil {
+			return err
+		}
+		if op == "remove" || !strings.EqualFold(name, string(role)) {
+			return fmt.Errorf("%w: displayName of group %s", ErrSCIMMutability, role)
+		}
+		return nil
+
+	case "members":
+		var ids []uint
+		switch {
+		case path.Filter != nil:
+			if op != "remove" {
+				return fmt.Errorf("%w: members[...] can only be removed", scim.ErrInvalidPath)
+			}
+			group, err := s.group(role, true)
+			if err != nil {
+				return err
+			}
+			for _, member := range group.Members {
+				if scim.Match(path.Filter, map[string][]string{"value": {member.Value}, "display": {member.Display}}) {
+					id, _ := strconv.ParseUint(member.Value, 10, 32)
+					ids = append(ids, uint(id))
+				}
+			}
+		case op == "remove" && len(value) == 0:
+			// Without a value, remove takes every member out.
+			group, err := s.group(role, true)
+			if err != nil {
+				return err
+			}
+			if ids, err = memberIDs(group.Members); err != nil {
+				return err
+			}
+		default:
+			members, err := scimValues(value)
+			if err != nil {
+				return err
+			}
+			if ids, err = memberIDs(members); err != nil {
+				return err
+			}
+		}
+
+		switch op {
+		case "add":
+			for _, id := range ids {
+				if err := s.addMember(actor, role, id); err != nil {
+					return err
+				}
+			}
+		case "remove":
+			for _, id := range ids {
+				if err := s.removeMember(actor, role, id); err != nil {
+					return err
+				}
+			}
+		case "replace":
+			return s.setMembers(actor, role, ids)
+		default:
+			return fmt.Errorf("%w: unknown op %q", ErrSCIMInvalidValue, op)
+		}
+		return nil
+
+	case "id", "meta":
+		return fmt.Errorf("%w: %s", ErrSCIMMutability, path.Attr)
+	}
+	return fmt.Errorf("%w: groups have no attribute %q", scim.ErrInvalidPath, path.Attr)
+}
+
+// setMembers grants the role to every account in ids and revokes it from
+// every other account that holds it. Every account is checked before any
+// is changed, so a member that cannot be added or removed fails the whole
+// request.
+func (s *scimService) setMembers(actor domain.Actor, role domain.Role, ids []uint) error {
+	group, err := s.group(role, true)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[uint]bool, len(ids))
+	var add, remove []uint
+	for _, id := range ids {
+		keep[id] = true
+		if err := s.checkMember(role, id, true); err != nil {
+			return err
+		}
+		add = append(add, id)
+	}
+	for _, member := range group.Members {
+		id, _ := strconv.ParseUint(member.Value, 10, 32)
+		if !keep[uint(id)] {
+			if err := s.checkMember(role, uint(id), false); err != nil {
+				return err
+			}
+			remove = append(remove, uint(id))
+		}
+	}
+
+	for _, id := range add {
+		if err := s.users.GrantRole(actor, id, role); err != nil {
+			return err
+		}
+	}
+	for _, id := range remove {
+		if err := s.users.RevokeRole(actor, id, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMember tells whether the role can be granted to or revoked from the
+// account. Nothing needs checking when the account already is or is not a
+// member.
+func (s *scimService) checkMember(role domain.Role, id uint, member bool) error {
+	user, err := s.users.GetUserByID(id)
+	switch {
+	case err == ErrUserNotFound:
+		return fmt.Errorf("%w: no user with id %d", ErrSCIMInvalidValue, id)
+	case err != nil:
+		return err
+	case user.HasRole(role) == member:
+		return nil
+	case user.HasRole(domain.RoleAdmin):
+		return ErrSCIMAdminAccount
+	case !member && len(user.Roles) == 1:
+		return fmt.Errorf("%w: user %d would be left without a role", ErrSCIMInvalidValue, id)
+	}
+	return nil
+}
+
+func (s *scimService) addMember(actor domain.Actor, role domain.Role, id uint) error {
+	if err := s.checkMember(role, id, true); err != nil {
+		return err
+	}
+	return s.users.GrantRole(actor, id, role)
+}
+
+func (s *scimService) removeMember(actor domain.Actor, role domain.Role, id uint) error {
+	if err := s.checkMember(role, id, false); err != nil {
+		return err
+	}
+	return s.users.RevokeRole(actor, id, role)
+}
+
+func (s *scimService) group(role domain.Role, withMembers bool) (*domain.SCIMGroup, error) {
+	group := &domain.SCIMGroup{
+		Schemas:     []string{domain.SCIMGroupSchema},
+		ID:          string(role),
+		DisplayName: string(role),
+		Members:     []domain.SCIMValue{},
+		Meta:        &domain.SCIMMeta{ResourceType: "Group"},
+	}
+	if !withMembers {
+		return group, nil
+	}
+
+	users, _, err := s.repo.ListSCIM(scim.Comparison{Attr: "roles", Op: scim.OpEqual, Value: string(role)}, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		group.Members = append(group.Members, domain.SCIMValue{
+			Value:   strconv.FormatUint(uint64(user.ID), 10),
+			Display: user.Username,
+			Type:    "User",
+		})
+	}
+	return group, nil
+}
+
+func groupAttributes(group *domain.SCIMGroup) map[string][]string {
+	attrs := map[string][]string{
+		"id":          {group.ID},
+		"displayname": {group.DisplayName},
+	}
+	for _, member := range group.Members {
+		attrs["members"] = append(attrs["members"], member.Value)
+		attrs["members.value"] = append(attrs["members.value"], member.Value)
+	}
+	return attrs
+}
+
+// patchUser applies one PATCH operation to the changed state.
+func patchUser(change *scimUserChange, op domain.SCIMPatchOperation) error {
+	operation := strings.ToLower(op.Op)
+	if operation != "add" && operation != "replace" && operation != "remove" {
+		return fmt.Errorf("%w: unknown op %q", ErrSCIMInvalidValue, op.Op)
+	}
+
+	if op.Path == "" {
+		if operation == "remove" {
+			return fmt.Errorf("%w: remove needs a path", scim.ErrInvalidPath)
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return fmt.Errorf("%w: a patch without a path takes an object", ErrSCIMInvalidValue)
+		}
+		for name, value := range values {
+			path, err := scim.ParsePath(name)
+			if err != nil {
+				return err
+			}
+			if err := setUserAttribute(change, operation, path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := scim.ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if operation == "remove" {
+		return removeUserAttribute(change, path, op.Value)
+	}
+	return setUserAttribute(change, operation, path, op.Value)
+}
+
+func setUserAttribute(change *scimUserChange, op string, path *scim.Path, value json.RawMessage) error {
+	attr := path.Attr
+	if path.SubAttr != "" {
+		attr += "." + path.SubAttr
+	}
+	user := &change.user
+
+	var err error
+	switch attr {
+	case "username":
+		user.Username, err = scimString(value)
+	case "name":
+		var name domain.SCIMName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return fmt.Errorf("%w: name takes an object", ErrSCIMInvalidValue)
+		}
+		if name.GivenName != "" {
+			user.FirstName = name.GivenName
+		}
+		if name.FamilyName != "" {
+			user.LastName = name.FamilyName
+		}
+	case "name.givenname":
+		user.FirstName, err = scimString(value)
+	case "name.familyname":
+		user.LastName, err = scimString(value)
+	case "emails":
+		var emails []domain.SCIMValue
+		if emails, err = scimValues(value); err == nil {
+			user.Email = primaryEmail(emails)
+		}
+	case "emails.value":
+		user.Email, err = scimString(value)
+	case "active":
+		var active bool
+		if active, err = scimBool(value); err == nil {
+			change.active = &active
+		}
+	case "password":
+		change.password, err = scimString(value)
+	case "roles":
+		var values []domain.SCIMValue
+		if values, err = scimValues(value); err != nil {
+			return err
+		}
+		roles, err := scimRoles(values)
+		if err != nil {
+			return err
+		}
+		if op == "add" {
+			for _, role := range roles {
+				if !user.HasRole(role) {
+					user.Roles = append(user.Roles, role)
+				}
+			}
+		} else {
+			user.Roles = roles
+		}
+	case "displayname", "name.formatted", "externalid":
+		// displayName and the formatted name are derived from the given and
+		// family names, and externalId is not kept.
+	case "id", "groups", "meta":
+		return fmt.Errorf("%w: %s", ErrSCIMMutability, attr)
+	default:
+		return fmt.Errorf("%w: users have no attribute %q", scim.ErrInvalidPath, attr)
+	}
+	return err
+}
+
+func removeUserAttribute(change *scimUserChange, path *scim.Path, value json.RawMessage) error {
+	user := &change.user
+	switch path.Attr {
+	case "emails", "emails.value":
+		user.Email = ""
+	case "roles":
+		var remove []domain.Role
+		switch {
+		case path.Filter != nil:
+			for _, role := range user.Roles {
+				if scim.Match(path.Filter, map[string][]string{"value": {string(role)}, "display": {string(role)}}) {
+					remove = append(remove, role)
+				}
+			}
+		case len(value) > 0:
+			values, err := scimValues(value)
+			if err != nil {
+				return err
+			}
+			if remove, err = scimRoles(values); err != nil {
+				return err
+			}
+		default:
+			remove = user.Roles
+		}
+
+		roles := make([]domain.Role, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			if !(&domain.User{Roles: remove}).HasRole(role) {
+				roles = append(roles, role)
+			}
+		}
+		user.Roles = roles
+	case "displayname", "name.formatted", "externalid":
+	case "username", "name", "name.givenname", "name.familyname", "active", "password":
+		return fmt.Errorf("%w: %s cannot be removed", ErrSCIMInvalidValue, path.Attr)
+	case "id", "groups", "meta":
+		return fmt.Errorf("%w: %s", ErrSCIMMutability, path.Attr)
+	default:
+		return fmt.Errorf("%w: users have no attribute %q", scim.ErrInvalidPath, path.Attr)
+	}
+	return nil
+}
+
+// fromSCIMUser builds the state a full User resource describes on top of
+// base.
+func fromSCIMUser(in *domain.SCIMUser, base *domain.User) (*scimUserChange, error) {
+	change := &scimUserChange{user: *base, password: in.Password, active: in.Active}
+	user := &change.user
+
+	user.Username = in.UserName
+	if in.Name != nil {
+		if in.Name.GivenName != "" {
+			user.FirstName = in.Name.GivenName
+		}
+		if in.Name.FamilyName != "" {
+			user.LastName = in.Name.FamilyName
+		}
+	}
+	user.Email = primaryEmail(in.Emails)
+	if in.Roles != nil {
+		roles, err := scimRoles(in.Roles)
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = roles
+	}
+	return change, nil
+}
+
+func toSCIMUser(user *domain.User) *domain.SCIMUser {
+	active := user.Status == domain.UserActive
+	created, modified := user.CreatedAt, user.UpdatedAt
+	formatted := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+	out := &domain.SCIMUser{
+		Schemas:  []string{domain.SCIMUserSchema},
+		ID:       strconv.FormatUint(uint64(user.ID), 10),
+		UserName: user.Username,
+		Name: &domain.SCIMName{
+			Formatted:  formatted,
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		},
+		DisplayName: formatted,
+		Active:      &active,
+		Meta: &domain.SCIMMeta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &modified,
+		},
+	}
+	if user.Email != "" {
+		out.Emails = []domain.SCIMValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	for _, role := range user.Roles {
+		out.Roles = append(out.Roles, domain.SCIMValue{Value: string(role)})
+		if _, ok := scimRole(string(role)); ok {
+			out.Groups = append(out.Groups, domain.SCIMValue{Value: string(role), Display: string(role)})
+		}
+	}
+	return out
+}
+
+func newSCIMList(total int64, startIndex int) *domain.SCIMListResponse {
+	return &domain.SCIMListResponse{
+		Schemas:      []string{domain.SCIMListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		Resources:    []interface{}{},
+	}
+}
+
+// scimPage clamps the 1-based startIndex and the count of a listing.
+func scimPage(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+// scimRole finds the role a group id or role value names, ignoring case.
+func scimRole(name string) (domain.Role, bool) {
+	for _, role := range scimGroupRoles {
+		if strings.EqualFold(name, string(role)) {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+func scimRoles(values []domain.SCIMValue) ([]domain.Role, error) {
+	roles := make([]domain.Role, 0, len(values))
+	for _, value := range values {
+		role, ok := scimRole(value.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not a role", ErrSCIMInvalidValue, value.Value)
+		}
+		if !(&domain.User{Roles: roles}).HasRole(role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+func memberIDs(members []domain.SCIMValue) ([]uint, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a user id", ErrSCIMInvalidValue, member.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// primaryEmail picks the primary address, or the first one.
+func primaryEmail(emails []domain.SCIMValue) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func scimString(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", fmt.Errorf("%w: a string is expected", ErrSCIMInvalidValue)
+	}
+	return s, nil
+}
+
+// scimBool also accepts "True" and "False" as strings, which some clients
+// send for active.
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("%w: a boolean is expected", ErrSCIMInvalidValue)
+}
+
+// scimValues reads a multi-valued attribute given as a list or as a single
+// value.
+func scimValues(value json.RawMessage) ([]domain.SCIMValue, error) {
+	var values []domain.SCIMValue
+	if err := json.Unmarshal(value, &values); err == nil {
+		return values, nil
+	}
+	var single domain.SCIMValue
+	if err := json.Unmarshal(value, &single); err == nil {
+		return []domain.SCIMValue{single}, nil
+	}
+	return nil, fmt.Errorf("%w: a list of values is expected", ErrSCIMInvalidValue)
+}
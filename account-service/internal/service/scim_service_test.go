@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+func newTestSCIMService(t *testing.T) (*testUserService, SCIMService) {
+	t.Helper()
+
+	ts := newTestUserService(t)
+	return ts, NewSCIMService(ts.users, ts, ts.passwords, ts.audit)
+}
+
+func patch(ops ...domain.SCIMPatchOperation) *domain.SCIMPatchRequest {
+	return &domain.SCIMPatchRequest{Operations: ops}
+}
+
+func op(op, path, value string) domain.SCIMPatchOperation {
+	var raw json.RawMessage
+	if value != "" {
+		raw = json.RawMessage(value)
+	}
+	return domain.SCIMPatchOperation{Op: op, Path: path, Value: raw}
+}
+
+func TestSCIMPatchUser(t *testing.T) {
+	actor := domain.Actor{ClientID: "idp"}
+	for _, tc := range []struct {
+		name  string
+		req   *domain.SCIMPatchRequest
+		check func(t *testing.T, user *domain.User)
+	}{
+		{
+			"replace sub-attributes",
+			patch(
+				op("replace", "name.givenName", `"Jane"`),
+				op("Replace", "emails[type eq \"work\"].value", `"jane@example.org"`),
+			),
+			func(t *testing.T, user *domain.User) {
+				if user.FirstName != "Jane" || user.LastName != "User" || user.Email != "jane@example.org" {
+					t.Errorf("got %s %s <%s>", user.FirstName, user.LastName, user.Email)
+				}
+			},
+		},
+		{
+			"replace without a path",
+			patch(op("replace", "", `{"userName": "jane", "name": {"familyName": "Doe"}, "active": false}`)),
+			func(t *testing.T, user *domain.User) {
+				if user.Username != "jane" || user.LastName != "Doe" || user.Status != domain.UserDisabled {
+					t.Errorf("got %s %s, %s", user.Username, user.LastName, user.Status)
+				}
+			},
+		},
+		{
+			"add roles keeps the others",
+			patch(op("add", "roles", `[{"value": "doctor"}]`)),
+			func(t *testing.T, user *domain.User) {
+				if want := []domain.Role{domain.RoleUser, domain.RoleDoctor}; !reflect.DeepEqual(user.Roles, want) {
+					t.Errorf("roles = %v, want %v", user.Roles, want)
+				}
+			},
+		},
+		{
+			"remove roles by filter",
+			patch(
+				op("add", "roles", `[{"value": "Manager"}, {"value": "Doctor"}]`),
+				op("remove", "roles[value eq \"Manager\"]", ""),
+			),
+			func(t *testing.T, user *domain.User) {
+				if want := []domain.Role{domain.RoleUser, domain.RoleDoctor}; !reflect.DeepEqual(user.Roles, want) {
+					t.Errorf("roles = %v, want %v", user.Roles, want)
+				}
+			},
+		},
+		{
+			"remove emails",
+			patch(op("remove", "emails", "")),
+			func(t *testing.T, user *domain.User) {
+				if user.Email != "" {
+					t.Errorf("email = %q, want none", user.Email)
+				}
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, svc := newTestSCIMService(t)
+			user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+			user.Email = "jsmith@example.org"
+			ts.users.Update(user)
+
+			if _, err := svc.PatchUser(actor, user.ID, tc.req); err != nil {
+				t.Fatalf("patch: %v", err)
+			}
+			saved, _ := ts.users.GetByID(user.ID)
+			tc.check(t, saved)
+		})
+	}
+}
+
+func TestSCIMPatchUserAppliesAllOrNothing(t *testing.T) {
+	ts, svc := newTestSCIMService(t)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	_, err := svc.PatchUser(domain.Actor{ClientID: "idp"}, user.ID, patch(
+		op("replace", "userName", `"jane"`),
+		op("replace", "active", `false`),
+		op("replace", "password", `"short"`),
+	))
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("patch with a weak password: got %v, want a validation error", err)
+	}
+
+	saved, _ := ts.users.GetByID(user.ID)
+	if saved.Username != "jsmith" || saved.Status != domain.UserActive || saved.Password != user.Password {
+		t.Errorf("rejected patch changed the account: %s, %s", saved.Username, saved.Status)
+	}
+	if len(ts.audit.events) != 0 {
+		t.Errorf("rejected patch was audited: %v", ts.audit.events)
+	}
+}
+
+func TestSCIMPatchUserPasswordSignsOut(t *testing.T) {
+	ts, svc := newTestSCIMService(t)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+	signedIn, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "Correct-Horse-42"}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	waitForNextSecond()
+	if _, err := svc.PatchUser(domain.Actor{ClientID: "idp"}, user.ID, patch(op("replace", "password", `"Battery-Staple-97"`))); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if _, err := ts.ValidateToken(signedIn.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("token issued before the change: got %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestSCIMCannotManageAdmins(t *testing.T) {
+	ts, svc := newTestSCIMService(t)
+	actor := domain.Actor{ClientID: "idp"}
+	admin := ts.addUser(t, "admin", "Correct-Horse-42", domain.RoleAdmin, domain.RoleDoctor)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	if _, err := svc.PatchUser(actor, admin.ID, patch(op("replace", "password", `"Battery-Staple-97"`))); err != ErrSCIMAdminAccount {
+		t.Errorf("set an admin's password: got %v, want %v", err, ErrSCIMAdminAccount)
+	}
+	if err := svc.DeleteUser(actor, admin.ID); err != ErrSCIMAdminAccount {
+		t.Errorf("delete an admin: got %v, want %v", err, ErrSCIMAdminAccount)
+	}
+	if _, err := svc.PatchUser(actor, user.ID, patch(op("add", "roles", `[{"value": "Admin"}]`))); !errors.Is(err, ErrSCIMInvalidValue) {
+		t.Errorf("grant Admin: got %v, want %v", err, ErrSCIMInvalidValue)
+	}
+	if _, err := svc.GetGroup("Admin", true); err != ErrGroupNotFound {
+		t.Errorf("get the Admin group: got %v, want %v", err, ErrGroupNotFound)
+	}
+
+	// Replacing the Doctor group would take Doctor from the admin, so the
+	// whole request is refused and jsmith does not become a doctor either.
+	_, err := svc.ReplaceGroup(actor, "Doctor", &domain.SCIMGroup{Members: []domain.SCIMValue{{Value: "2"}}})
+	if err != ErrSCIMAdminAccount {
+		t.Errorf("replace the Doctor group: got %v, want %v", err, ErrSCIMAdminAccount)
+	}
+	saved, _ := ts.users.GetByID(user.ID)
+	if saved.HasRole(domain.RoleDoctor) {
+		t.Error("refused group replacement made jsmith a doctor")
+	}
+	saved, _ = ts.users.GetByID(admin.ID)
+	if !saved.HasRole(domain.RoleDoctor) || saved.Password != admin.Password {
+		t.Error("admin account was changed")
+	}
+}
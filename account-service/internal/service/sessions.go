@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the devices the user is signed in on. The session
+// the request came from is flagged as current.
+func (s *userService) ListSessions(userID, currentSessionID uint) ([]domain.Session, error) {
+	if _, err := s.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.ListActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one device. Its refresh token stops
+// working at once and its access tokens as soon as services pick up the
+// revocation list.
+func (s *userService) RevokeSession(actor domain.Actor, userID, sessionID uint) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.revokeSession(session.ID, userID); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditSessionRevoked, userID, strconv.FormatUint(uint64(session.ID), 10))
+}
+
+// revokeOtherSessions ends every session of the user except the one given,
+// which is zero when the change did not come from one of their sessions.
+func (s *userService) revokeOtherSessions(userID, keepSessionID uint) error {
+	if keepSessionID == 0 {
+		return s.revokeUserTokens(userID)
+	}
+
+	sessions, err := s.sessionRepo.ListActiveByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := s.revokeSession(session.ID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *userService) revokeSession(sessionID, userID uint) error {
+	if err := s.sessionRepo.Revoke(sessionID); err != nil {
+		return err
+	}
+	return s.revocationRepo.Create(&domain.TokenRevocation{
+		UserID:    userID,
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(accessTokenTTL),
+	})
+}
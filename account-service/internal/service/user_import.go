@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
+)
+
+var ErrImportTooLarge = errors.New("an import is limited to 1000 rows")
+
+const (
+	maxImportRows                = 1000
+	temporaryPasswordSendTimeout = 30 * time.Second
+)
+
+// ImportUsers creates many accounts at once. Every row is validated before
+// anything is written, and the accounts are created in one transaction, so
+// an import either creates all of its rows or none.
+func (s *userService) ImportUsers(actor domain.Actor, rows []domain.ImportUserRow, opts domain.ImportOptions) (*domain.ImportResult, error) {
+	if actor.ImpersonatorID != 0 {
+		return nil, ErrImpersonationForbidden
+	}
+	if len(rows) == 0 {
+		return nil, &ValidationError{Fields: map[string][]string{"rows": {"must not be empty"}}}
+	}
+	if len(rows) > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	rowErrors, err := s.validateImport(rows, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.ImportResult{DryRun: opts.DryRun, Rows: len(rows), Errors: rowErrors}
+	if len(result.Errors) > 0 || opts.DryRun {
+		return result, nil
+	}
+
+	users := make([]domain.User, len(rows))
+	generated := make([]domain.GeneratedPassword, 0)
+	for i, row := range rows {
+		users[i] = domain.User{
+			Username:  row.Username,
+			Email:     row.Email,
+			FirstName: row.FirstName,
+			LastName:  row.LastName,
+			Roles:     row.Roles,
+			Status:    domain.UserActive,
+			Source:    domain.UserSourceLocal,
+		}
+
+		plain := row.Password
+		if plain == "" {
+			if plain, err = s.passwords.Generate(row.Username); err != nil {
+				return nil, err
+			}
+			users[i].MustChangePassword = true
+			generated = append(generated, domain.GeneratedPassword{Username: row.Username, Password: plain})
+		}
+		if err := s.passwords.Set(&users[i], plain); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.CreateBatch(users); err != nil {
+		return nil, err
+	}
+	result.Created = len(users)
+
+	for _, user := range users {
+		if err := s.audit.Record(actor, domain.AuditAccountCreated, user.ID, ""); err != nil {
+			return nil, err
+		}
+		for _, role := range user.Roles {
+			if err := s.audit.Record(actor, domain.AuditRoleGranted, user.ID, string(role)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.SendPasswords {
+		s.sendTemporaryPasswords(users, generated)
+	} else {
+		result.Passwords = generated
+	}
+	return result, nil
+}
+
+// ExportUsers walks every account matching the filter, in batches.
+func (s *userService) ExportUsers(filter domain.UserFilter, fn func([]domain.User) error) error {
+	if filter.Role != "" && !filter.Role.Valid() {
+		return ErrInvalidRole
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return ErrInvalidStatus
+	}
+	return s.repo.Each(filter, fn)
+}
+
+// validateImport checks every row on its own and against the others and the
+// existing accounts, and returns the problems of each failing row.
+func (s *userService) validateImport(rows []domain.ImportUserRow, opts domain.ImportOptions) ([]domain.ImportRowError, error) {
+	usernames := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Username != "" {
+			usernames = append(usernames, row.Username)
+		}
+	}
+	existing, err := s.repo.ExistingUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, username := range existing {
+		taken[username] = true
+	}
+
+	var rowErrors []domain.ImportRowError
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		fields := map[string][]string{}
+		problem := func(field, message string) {
+			fields[field] = append(fields[field], message)
+		}
+
+		switch {
+		case row.Username == "":
+			problem("username", "is required")
+		case taken[row.Username]:
+			problem("username", "is already taken")
+		case seen[row.Username] != 0:
+			problem("username", fmt.Sprintf("repeats row %d", seen[row.Username]))
+		default:
+			seen[row.Username] = i + 1
+		}
+
+		if row.FirstName == "" {
+			problem("first_name", "is required")
+		}
+		if row.LastName == "" {
+			problem("last_name", "is required")
+		}
+
+		if row.Email != "" {
+			if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email {
+				problem("email", "is not a valid email address")
+			}
+		} else if opts.SendPasswords && row.Password == "" {
+			problem("email", "is required to send the temporary password")
+		}
+
+		if len(row.Roles) == 0 {
+			problem("roles", "is required")
+		}
+		for _, role := range row.Roles {
+			if !role.Valid() {
+				problem("roles", fmt.Sprintf("%q is not a role", role))
+			}
+		}
+
+		if row.Password != "" {
+			var invalid *ValidationError
+			err := s.passwords.Check(row.Username, row.Password)
+			if errors.As(err, &invalid) {
+				for field, problems := range invalid.Fields {
+					fields[field] = append(fields[field], problems...)
+				}
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(fields) > 0 {
+			rowErrors = append(rowErrors, domain.ImportRowError{Row: i + 1, Username: row.Username, Fields: fields})
+		}
+	}
+	return rowErrors, nil
+}
+
+func (s *userService) sendTemporaryPasswords(users []domain.User, generated []domain.GeneratedPassword) {
+	byUsername := make(map[string]*domain.User, len(users))
+	for i := range users {
+		byUsername[users[i].Username] = &users[i]
+	}
+
+	messages := make([]notify.Message, 0, len(generated))
+	for _, password := range generated {
+		user := byUsername[password.Username]
+		messages = append(messages, notify.Message{
+			To:      user.Email,
+			Subject: "Your new account",
+			Body: fmt.Sprintf(
+				"Hello %s,\n\nAn account has been created for you with the username %s and the temporary password\n\n%s\n\nYou will be asked to choose a new password when you first sign in.",
+				user.FirstName, user.Username, password.Password,
+			),
+		})
+	}
+
+	go func() {
+		for _, msg := range messages {
+			ctx, cancel := context.WithTimeout(context.Background(), temporaryPasswordSendTimeout)
+			if err := s.notifier.Send(ctx, msg); err != nil {
+				log.Printf("Failed to send temporary password to %s: %v", msg.To, err)
+			}
+			cancel()
+		}
+	}()
+}
@@ -1,111 +1,328 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/notify"
 	"github.com/sergeimurashev/hospital-system-api/account-service/internal/repository"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 )
 
 var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrInvalidSort        = errors.New("invalid sort field")
+	ErrInvalidStatus      = errors.New("invalid account status")
+	ErrInvalidToken       = errors.New("invalid refresh token")
+	ErrTokenReused        = errors.New("refresh token reuse detected")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+	// ErrPasswordChangeRequired is returned by SignIn for accounts that must
+	// set a new password through ChangePassword first.
+	ErrPasswordChangeRequired = errors.New("password change required")
+)
+
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 24 * time.Hour
 )
 
 type UserService interface {
-	SignUp(req *domain.SignUpRequest) error
-	SignIn(req *domain.SignInRequest) (*domain.TokenResponse, error)
-	RefreshToken(req *domain.RefreshTokenRequest) (*domain.TokenResponse, error)
+	SignUp(req *domain.SignUpRequest, client domain.ClientInfo) (*domain.SignUpResponse, error)
+	VerifyAccount(req *domain.VerifyAccountRequest, client domain.ClientInfo) error
+	ResendVerification(req *domain.ResendVerificationRequest) error
+	ApproveUser(actor domain.Actor, id uint) error
+	SetUserActive(actor domain.Actor, id uint, active bool) error
+	SignIn(req *domain.SignInRequest, client domain.ClientInfo) (*domain.SignInResponse, error)
+	ChangePassword(req *domain.ChangePasswordRequest, client domain.ClientInfo) (*domain.SignInResponse, error)
+	VerifyMFA(req *domain.MFAVerifyRequest, client domain.ClientInfo) (*domain.MFAVerifyResponse, error)
+	SignOut(actor domain.Actor, sessionID uint, jti string) error
+	RefreshToken(req *domain.RefreshTokenRequest, client domain.ClientInfo) (*domain.TokenResponse, error)
 	GetUserByID(id uint) (*domain.User, error)
-	UpdateUser(id uint, req *domain.UpdateUserRequest) error
-	DeleteUser(id uint) error
-	ListUsers(offset, limit int) ([]domain.User, error)
-	CreateUser(req *domain.CreateUserRequest) error
-	ValidateToken(token string) (*jwt.Token, error)
-	GetJWTSecret() string
+	GetUsersByIDs(ids []uint) ([]domain.User, error)
+	UpdateUser(actor domain.Actor, id uint, req *domain.UpdateUserRequest) error
+	AdminUpdateUser(actor domain.Actor, id uint, req *domain.AdminUpdateUserRequest) error
+	SaveAccount(actor domain.Actor, next *domain.User, password string) error
+	GrantRole(actor domain.Actor, id uint, role domain.Role) error
+	RevokeRole(actor domain.Actor, id uint, role domain.Role) error
+	DeleteUser(actor domain.Actor, id uint) error
+	ListDeletedUsers(offset, limit int) (*domain.UserPage, error)
+	RestoreUser(actor domain.Actor, id uint, req *domain.RestoreUserRequest) error
+	PurgeUser(actor domain.Actor, id uint) error
+	PurgeExpiredUsers(actor domain.Actor) (int, error)
+	ExpirePendingUsers(actor domain.Actor) (int, error)
+	ListUsers(filter domain.UserFilter, offset, limit int) (*domain.UserPage, error)
+	CreateUser(actor domain.Actor, req *domain.CreateUserRequest) error
+	ImportUsers(actor domain.Actor, rows []domain.ImportUserRow, opts domain.ImportOptions) (*domain.ImportResult, error)
+	ExportUsers(filter domain.UserFilter, fn func([]domain.User) error) error
+	ValidateToken(token string) (*auth.Claims, error)
+	Introspect(token string) *domain.IntrospectionResponse
+	RevokeUserTokens(actor domain.Actor, userID uint) error
+	Impersonate(actor domain.Actor, userID uint, req *domain.ImpersonateRequest) (*domain.ImpersonationResponse, error)
+	ListSessions(userID, currentSessionID uint) ([]domain.Session, error)
+	RevokeSession(actor domain.Actor, userID, sessionID uint) error
+	UnlockUser(actor domain.Actor, id uint) error
+	RequirePasswordChange(actor domain.Actor, id uint) error
+	ListLoginAttempts(username, ip string, offset, limit int) ([]domain.LoginAttempt, error)
+	GetRevocationList() (*domain.RevocationList, error)
+	EnrollMFA(actor domain.Actor) (*domain.MFAEnrollResponse, error)
+	EnrollMFAWithChallenge(challengeToken string) (*domain.MFAEnrollResponse, error)
+	ConfirmMFA(actor domain.Actor, code string) ([]string, error)
+	DisableMFA(actor domain.Actor, code string) error
+	RegenerateRecoveryCodes(actor domain.Actor, code string) ([]string, error)
+	ResetMFA(actor domain.Actor, userID uint) error
+	GetMFAPolicy() (*domain.MFAPolicy, error)
+	SetMFAPolicy(actor domain.Actor, policy *domain.MFAPolicy) error
 }
 
 type userService struct {
-	repo      repository.UserRepository
-	jwtSecret string
+	repo           repository.UserRepository
+	sessionRepo    repository.SessionRepository
+	revocationRepo repository.RevocationRepository
+	mfaRepo        repository.MFARepository
+	loginRepo      repository.LoginAttemptRepository
+	verifyRepo     repository.VerificationRepository
+	passwords      *Passwords
+	audit          AuditService
+	keys           KeyService
+	// retention is how long a deleted account is kept before it may be
+	// purged.
+	retention time.Duration
+	// pendingTTL is how long an account may stay pending verification or
+	// approval before it is deleted. Zero keeps pending accounts.
+	pendingTTL time.Duration
+	// registration decides whether accounts created by SignUp need to be
+	// verified through notifier or approved before they can sign in.
+	registration domain.RegistrationMode
+	notifier     notify.Notifier
+	// authenticators are asked in order to check a password at sign-in.
+	authenticators []Authenticator
 }
 
-func NewUserService(repo repository.UserRepository, jwtSecret string) UserService {
+func NewUserService(
+	repo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	revocationRepo repository.RevocationRepository,
+	mfaRepo repository.MFARepository,
+	loginRepo repository.LoginAttemptRepository,
+	verifyRepo repository.VerificationRepository,
+	passwords *Passwords,
+	audit AuditService,
+	keys KeyService,
+	retention time.Duration,
+	pendingTTL time.Duration,
+	registration domain.RegistrationMode,
+	notifier notify.Notifier,
+	authenticators []Authenticator,
+) UserService {
 	return &userService{
-		repo:      repo,
-		jwtSecret: jwtSecret,
+		repo:           repo,
+		sessionRepo:    sessionRepo,
+		revocationRepo: revocationRepo,
+		mfaRepo:        mfaRepo,
+		loginRepo:      loginRepo,
+		verifyRepo:     verifyRepo,
+		passwords:      passwords,
+		audit:          audit,
+		keys:           keys,
+		retention:      retention,
+		pendingTTL:     pendingTTL,
+		registration:   registration,
+		notifier:       notifier,
+		authenticators: authenticators,
 	}
 }
 
-func (s *userService) SignUp(req *domain.SignUpRequest) error {
-	existingUser, err := s.repo.GetByUsername(req.Username)
+func (s *userService) SignIn(req *domain.SignInRequest, client domain.ClientInfo) (*domain.SignInResponse, error) {
+	user, err := s.checkCredentials(req.Username, req.Password, client)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if existingUser != nil {
-		return ErrUserAlreadyExists
+	if user.MustChangePassword {
+		return nil, ErrPasswordChangeRequired
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	return s.completeSignIn(user, client)
+}
+
+// ChangePassword signs in with the current password while replacing it. It
+// is the way in for accounts flagged with MustChangePassword, and it ends
+// every existing session of the account.
+func (s *userService) ChangePassword(req *domain.ChangePasswordRequest, client domain.ClientInfo) (*domain.SignInResponse, error) {
+	user, err := s.checkCredentials(req.Username, req.CurrentPassword, client)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if user.Source != domain.UserSourceLocal {
+		return nil, ErrDirectoryAccount
 	}
 
-	user := &domain.User{
-		Username:  req.Username,
-		Password:  string(hashedPassword),
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Roles:     []domain.Role{domain.RoleUser},
+	if err := s.passwords.Set(user, req.Password); err != nil {
+		return nil, err
+	}
+	user.MustChangePassword = false
+	if err := s.repo.Update(user); err != nil {
+		return nil, err
 	}
 
-	return s.repo.Create(user)
+	if err := s.audit.Record(client.As(user.ID), domain.AuditPasswordChanged, user.ID, ""); err != nil {
+		return nil, err
+	}
+	if err := s.revokeUserTokens(user.ID); err != nil {
+		return nil, err
+	}
+	return s.completeSignIn(user, client)
 }
 
-func (s *userService) SignIn(req *domain.SignInRequest) (*domain.TokenResponse, error) {
-	user, err := s.repo.GetByUsername(req.Username)
-	if err != nil {
+// checkCredentials verifies a username and password with the authenticator
+// chain under the sign-in throttle, recording failures. They are only
+// cleared by completeSignIn once tokens are issued, so a correct password
+// does not reset the count for a second factor that keeps failing.
+// Accounts that are still pending or disabled are refused once the
+// password has checked out.
+func (s *userService) checkCredentials(username, password string, client domain.ClientInfo) (*domain.User, error) {
+	if err := s.checkThrottle(username, client.IP); err != nil {
 		return nil, err
 	}
-	if user == nil {
-		return nil, ErrInvalidCredentials
-	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	identity, err := s.authenticate(username, password)
+	switch err {
+	case nil:
+	case ErrUnknownUser:
+		if err := s.recordFailure(username, 0, client, domain.LoginFailureUnknownUser); err != nil {
+			return nil, err
+		}
 		return nil, ErrInvalidCredentials
+	case ErrInvalidCredentials:
+		var userID uint
+		user, err := s.repo.GetByUsername(username)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			userID = user.ID
+		}
+		if err := s.recordFailure(username, userID, client, domain.LoginFailureInvalidPassword); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	default:
+		return nil, err
 	}
 
-	accessToken, refreshToken, err := s.generateTokens(user)
+	user, err := s.accountFor(identity, client)
 	if err != nil {
 		return nil, err
 	}
 
-	return &domain.TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	switch user.Status {
+	case domain.UserPendingVerification:
+		return nil, ErrVerificationRequired
+	case domain.UserPendingApproval:
+		return nil, ErrApprovalPending
+	case domain.UserDisabled:
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
+}
+
+// RequirePasswordChange makes the user pick a new password at their next
+// sign-in.
+func (s *userService) RequirePasswordChange(actor domain.Actor, id uint) error {
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.Source != domain.UserSourceLocal {
+		return ErrDirectoryAccount
+	}
+
+	user.MustChangePassword = true
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	return s.audit.Record(actor, domain.AuditPasswordChangeRequired, user.ID, "")
+}
+
+func (s *userService) SignOut(actor domain.Actor, sessionID uint, jti string) error {
+	userID := actor.UserID
+	if err := s.audit.Record(actor, domain.AuditSignOut, userID, ""); err != nil {
+		return err
+	}
+
+	if jti != "" {
+		if err := s.revocationRepo.Create(&domain.TokenRevocation{
+			JTI:       jti,
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(accessTokenTTL),
+		}); err != nil {
+			return err
+		}
+	}
+
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return nil
+	}
+	// Access tokens issued by earlier refreshes of the session end with it.
+	return s.revokeSession(session.ID, userID)
 }
 
-func (s *userService) RefreshToken(req *domain.RefreshTokenRequest) (*domain.TokenResponse, error) {
-	token, err := jwt.Parse(req.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
+func (s *userService) RefreshToken(req *domain.RefreshTokenRequest, client domain.ClientInfo) (*domain.TokenResponse, error) {
+	token, err := s.sessionRepo.GetRefreshTokenByHash(hashToken(req.RefreshToken))
 	if err != nil {
 		return nil, err
 	}
+	if token == nil {
+		return nil, ErrInvalidToken
+	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid token claims")
+	session, err := s.sessionRepo.GetByID(token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return nil, ErrInvalidToken
 	}
 
-	userID := uint(claims["user_id"].(float64))
+	// A token that was already exchanged is being replayed, so whoever holds
+	// the family can no longer be trusted.
+	if token.RotatedAt != nil {
+		if err := s.revokeSession(session.ID, session.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	rotated, err := s.sessionRepo.MarkRefreshTokenRotated(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		if err := s.revokeSession(session.ID, session.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
 
-	user, err := s.repo.GetByID(userID)
+	user, err := s.repo.GetByID(token.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -113,15 +330,14 @@ func (s *userService) RefreshToken(req *domain.RefreshTokenRequest) (*domain.Tok
 		return nil, ErrUserNotFound
 	}
 
-	accessToken, refreshToken, err := s.generateTokens(user)
-	if err != nil {
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.LastUsedAt = time.Now()
+	if err := s.sessionRepo.Update(session); err != nil {
 		return nil, err
 	}
 
-	return &domain.TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.issueTokens(user, session)
 }
 
 func (s *userService) GetUserByID(id uint) (*domain.User, error) {
@@ -135,7 +351,16 @@ func (s *userService) GetUserByID(id uint) (*domain.User, error) {
 	return user, nil
 }
 
-func (s *userService) UpdateUser(id uint, req *domain.UpdateUserRequest) error {
+// GetUsersByIDs returns the users that exist among ids, ordered by ID.
+func (s *userService) GetUsersByIDs(ids []uint) ([]domain.User, error) {
+	return s.repo.ListByIDs(ids)
+}
+
+func (s *userService) UpdateUser(actor domain.Actor, id uint, req *domain.UpdateUserRequest) error {
+	if req.Password != "" && actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+
 	user, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
@@ -144,6 +369,9 @@ func (s *userService) UpdateUser(id uint, req *domain.UpdateUserRequest) error {
 		return ErrUserNotFound
 	}
 
+	if req.Email != "" {
+		user.Email = req.Email
+	}
 	if req.FirstName != "" {
 		user.FirstName = req.FirstName
 	}
@@ -151,25 +379,219 @@ func (s *userService) UpdateUser(id uint, req *domain.UpdateUserRequest) error {
 		user.LastName = req.LastName
 	}
 	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if user.Source != domain.UserSourceLocal {
+			return ErrDirectoryAccount
+		}
+		if err := s.passwords.Set(user, req.Password); err != nil {
+			return err
+		}
+		user.MustChangePassword = false
+	}
+
+	if err := s.repo.Update(user); err != nil {
+		return err
+	}
+	if req.Password != "" {
+		if err := s.revokeOtherSessions(user.ID, actor.SessionID); err != nil {
+			return err
+		}
+	}
+	return s.recordUpdate(actor, user.ID, req.Password != "")
+}
+
+// AdminUpdateUser lets an admin change any field of an account, including
+// its username and roles. Setting a password signs the user out everywhere.
+func (s *userService) AdminUpdateUser(actor domain.Actor, id uint, req *domain.AdminUpdateUserRequest) error {
+	if (req.Password != "" || req.Roles != nil) && actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if req.Username != "" && req.Username != user.Username {
+		existingUser, err := s.repo.GetByUsername(req.Username)
 		if err != nil {
 			return err
 		}
-		user.Password = string(hashedPassword)
+		if existingUser != nil {
+			return ErrUserAlreadyExists
+		}
+		user.Username = req.Username
+	}
+	if req.Email != "" {
+		user.Email = req.Email
+	}
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		user.LastName = req.LastName
+	}
+	if req.Password != "" {
+		if user.Source != domain.UserSourceLocal {
+			return ErrDirectoryAccount
+		}
+		if err := s.passwords.Set(user, req.Password); err != nil {
+			return err
+		}
 	}
 
-	return s.repo.Update(user)
+	// setRoles revokes the user's tokens, which covers a new password too.
+	if req.Roles != nil && !rolesEqual(req.Roles, user.Roles) {
+		if err := s.setRoles(actor, user, req.Roles); err != nil {
+			return err
+		}
+	} else {
+		if err := s.repo.Update(user); err != nil {
+			return err
+		}
+		if req.Password != "" {
+			// An admin setting their own password keeps the session they
+			// did it from, as with UpdateUser.
+			var keep uint
+			if user.ID == actor.UserID {
+				keep = actor.SessionID
+			}
+			if err := s.revokeOtherSessions(user.ID, keep); err != nil {
+				return err
+			}
+		}
+	}
+	return s.recordUpdate(actor, user.ID, req.Password != "")
 }
 
-func (s *userService) DeleteUser(id uint) error {
-	return s.repo.Delete(id)
+// SaveAccount brings the account with next's ID to the state next
+// describes: profile, roles and status, plus the password when one is
+// given. Everything is checked first and then written in one update, so
+// the change applies whole or not at all. Each change is audited, and the
+// user's tokens are revoked when the roles or password change or the
+// account stops being active.
+func (s *userService) SaveAccount(actor domain.Actor, next *domain.User, password string) error {
+	user, err := s.GetUserByID(next.ID)
+	if err != nil {
+		return err
+	}
+
+	rolesChanged := !rolesEqual(next.Roles, user.Roles)
+	if (password != "" || rolesChanged) && actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+	if next.Username != user.Username {
+		existingUser, err := s.repo.GetByUsername(next.Username)
+		if err != nil {
+			return err
+		}
+		if existingUser != nil {
+			return ErrUserAlreadyExists
+		}
+	}
+	if err := validateRoles(next.Roles); err != nil {
+		return err
+	}
+	if !next.HasRole(domain.RoleAdmin) || next.Status != domain.UserActive {
+		if err := s.ensureAnotherAdmin(user); err != nil {
+			return err
+		}
+	}
+	// The rest of the row is not the caller's to set.
+	next.CreatedAt = user.CreatedAt
+	next.Password = user.Password
+	next.Source = user.Source
+	next.MustChangePassword = user.MustChangePassword
+	if password != "" {
+		if user.Source != domain.UserSourceLocal {
+			return ErrDirectoryAccount
+		}
+		if err := s.passwords.Set(next, password); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Update(next); err != nil {
+		return err
+	}
+
+	if next.Username != user.Username || next.Email != user.Email ||
+		next.FirstName != user.FirstName || next.LastName != user.LastName || password != "" {
+		if err := s.recordUpdate(actor, user.ID, password != ""); err != nil {
+			return err
+		}
+	}
+	if err := s.recordRoleChanges(actor, user.ID, user.Roles, next.Roles); err != nil {
+		return err
+	}
+	if next.Status != user.Status {
+		if err := s.verifyRepo.InvalidateAllForUser(user.ID); err != nil {
+			return err
+		}
+		action := domain.AuditAccountEnabled
+		if next.Status != domain.UserActive {
+			action = domain.AuditAccountDisabled
+		}
+		if err := s.audit.Record(actor, action, user.ID, ""); err != nil {
+			return err
+		}
+	}
+	if password != "" || rolesChanged || (user.Status == domain.UserActive && next.Status != domain.UserActive) {
+		return s.revokeUserTokens(user.ID)
+	}
+	return nil
+}
+
+func (s *userService) recordUpdate(actor domain.Actor, userID uint, passwordChanged bool) error {
+	if err := s.audit.Record(actor, domain.AuditAccountUpdated, userID, ""); err != nil {
+		return err
+	}
+	if passwordChanged {
+		return s.audit.Record(actor, domain.AuditPasswordChanged, userID, "")
+	}
+	return nil
+}
+
+func (s *userService) DeleteUser(actor domain.Actor, id uint) error {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureAnotherAdmin(user); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.audit.Record(actor, domain.AuditAccountDeleted, id, ""); err != nil {
+		return err
+	}
+	return s.revokeUserTokens(id)
 }
 
-func (s *userService) ListUsers(offset, limit int) ([]domain.User, error) {
-	return s.repo.List(offset, limit)
+func (s *userService) ListUsers(filter domain.UserFilter, offset, limit int) (*domain.UserPage, error) {
+	if !repository.ValidUserSort(filter.Sort) {
+		return nil, ErrInvalidSort
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, ErrInvalidStatus
+	}
+
+	users, total, err := s.repo.List(filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.UserPage{Items: users, Total: total}, nil
 }
 
-func (s *userService) CreateUser(req *domain.CreateUserRequest) error {
+func (s *userService) CreateUser(actor domain.Actor, req *domain.CreateUserRequest) error {
+	if actor.ImpersonatorID != 0 {
+		return ErrImpersonationForbidden
+	}
+
 	existingUser, err := s.repo.GetByUsername(req.Username)
 	if err != nil {
 		return err
@@ -177,54 +599,251 @@ func (s *userService) CreateUser(req *domain.CreateUserRequest) error {
 	if existingUser != nil {
 		return ErrUserAlreadyExists
 	}
+	if err := validateRoles(req.Roles); err != nil {
+		return err
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	user := &domain.User{
+		Username:           req.Username,
+		Email:              req.Email,
+		FirstName:          req.FirstName,
+		LastName:           req.LastName,
+		Roles:              req.Roles,
+		MustChangePassword: req.MustChangePassword,
+		Status:             domain.UserActive,
+		Source:             domain.UserSourceLocal,
+	}
+	if err := s.passwords.Set(user, req.Password); err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		return err
+	}
+	if err := s.audit.Record(actor, domain.AuditAccountCreated, user.ID, ""); err != nil {
+		return err
+	}
+	for _, role := range user.Roles {
+		if err := s.audit.Record(actor, domain.AuditRoleGranted, user.ID, string(role)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *userService) ValidateToken(token string) (*auth.Claims, error) {
+	claims := &auth.Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, s.keys.Keyfunc, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
 	if err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid token claims")
+	}
+
+	revoked, err := s.revocationRepo.IsRevoked(claims.ID, claims.ClientID, claims.UserID, claims.SessionID, claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked && claims.Act != nil {
+		revoked, err = s.revocationRepo.IsRevoked("", "", claims.Act.UserID, claims.Act.SessionID, claims.IssuedAt.Time)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+func (s *userService) Introspect(token string) *domain.IntrospectionResponse {
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		return &domain.IntrospectionResponse{Active: false}
+	}
+
+	sub := claims.Subject
+	if claims.ClientID == "" {
+		sub = strconv.FormatUint(uint64(claims.UserID), 10)
+	}
+
+	return &domain.IntrospectionResponse{
+		Active:    true,
+		TokenType: "access_token",
+		Sub:       sub,
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+		Roles:     claims.Roles,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Jti:       claims.ID,
+		Sid:       claims.SessionID,
+		Act:       claims.Act,
+	}
+}
+
+func (s *userService) RevokeUserTokens(actor domain.Actor, userID uint) error {
+	// A revocation without a user would match every client token, which
+	// carries no user id.
+	if _, err := s.GetUserByID(userID); err != nil {
 		return err
 	}
+	if err := s.audit.Record(actor, domain.AuditTokensRevoked, userID, ""); err != nil {
+		return err
+	}
+	return s.revokeUserTokens(userID)
+}
 
-	user := &domain.User{
-		Username:  req.Username,
-		Password:  string(hashedPassword),
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Roles:     req.Roles,
+// revokeUserTokens invalidates every access token already issued to the user
+// and ends all of their sessions.
+func (s *userService) revokeUserTokens(userID uint) error {
+	now := time.Now()
+	if err := s.revocationRepo.Create(&domain.TokenRevocation{
+		// Tokens carry their iat in whole seconds, so the revocation does
+		// too; it then covers the tokens issued in earlier seconds and not
+		// the one about to be issued.
+		CreatedAt: now.Truncate(time.Second),
+		UserID:    userID,
+		ExpiresAt: now.Add(accessTokenTTL),
+	}); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllForUser(userID)
+}
+
+func (s *userService) GetRevocationList() (*domain.RevocationList, error) {
+	revocations, err := s.revocationRepo.ListActive()
+	if err != nil {
+		return nil, err
 	}
 
-	return s.repo.Create(user)
+	list := &domain.RevocationList{
+		JTIs:     []string{},
+		Sessions: []uint{},
+		Clients:  []string{},
+		Users:    []domain.RevokedUser{},
+	}
+	for _, revocation := range revocations {
+		if revocation.JTI != "" {
+			list.JTIs = append(list.JTIs, revocation.JTI)
+			continue
+		}
+		if revocation.SessionID != 0 {
+			list.Sessions = append(list.Sessions, revocation.SessionID)
+			continue
+		}
+		if revocation.ClientID != "" {
+			list.Clients = append(list.Clients, revocation.ClientID)
+			continue
+		}
+		list.Users = append(list.Users, domain.RevokedUser{
+			UserID:    revocation.UserID,
+			RevokedAt: revocation.CreatedAt,
+		})
+	}
+	return list, nil
 }
 
-func (s *userService) ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
+// startSession opens a new token family for the device. Signing in again
+// from the same device replaces its previous session.
+func (s *userService) startSession(userID uint, client domain.ClientInfo) (*domain.Session, error) {
+	if client.DeviceID != "" {
+		existing, err := s.sessionRepo.GetActiveByDevice(userID, client.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if err := s.sessionRepo.Revoke(existing.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	session := &domain.Session{
+		UserID:     userID,
+		DeviceID:   client.DeviceID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		LastUsedAt: time.Now(),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-func (s *userService) generateTokens(user *domain.User) (string, string, error) {
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"roles":   user.Roles,
-		"exp":     time.Now().Add(time.Hour).Unix(),
-	})
+func (s *userService) issueTokens(user *domain.User, session *domain.Session) (*domain.TokenResponse, error) {
+	accessToken, err := s.generateTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
 
-	accessTokenString, err := accessToken.SignedString([]byte(s.jwtSecret))
+	if err := s.sessionRepo.CreateRefreshToken(&domain.RefreshToken{
+		SessionID: session.ID,
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *userService) generateTokens(user *domain.User, session *domain.Session) (string, error) {
+	return signAccessToken(s.keys, &auth.Claims{
+		UserID:    user.ID,
+		Roles:     user.Roles,
+		SessionID: session.ID,
+	}, "", accessTokenTTL)
+}
+
+// signAccessToken stamps the claims with a fresh jti, subject and validity
+// window and signs them with the current key.
+func signAccessToken(keys KeyService, claims *auth.Claims, subject string, ttl time.Duration) (string, error) {
+	key, err := keys.SigningKey()
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	refreshTokenString, err := refreshToken.SignedString([]byte(s.jwtSecret))
+	jti, err := generateOpaqueToken()
 	if err != nil {
-		return "", "", err
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 	}
 
-	return accessTokenString, refreshTokenString, nil
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+func generateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
 
-func (s *userService) GetJWTSecret() string {
-	return s.jwtSecret
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sergeimurashev/hospital-system-api/account-service/internal/domain"
+)
+
+// waitForNextSecond sleeps until the wall clock enters a new second, so
+// tokens issued before and after the call have different iats.
+func waitForNextSecond() {
+	now := time.Now()
+	time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+}
+
+func TestChangePasswordIssuesUsableToken(t *testing.T) {
+	ts := newTestUserService(t)
+	ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+	client := domain.ClientInfo{IP: "10.0.0.1"}
+
+	before, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "Correct-Horse-42"}, client)
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	waitForNextSecond()
+	changed, err := ts.ChangePassword(&domain.ChangePasswordRequest{
+		Username:        "jsmith",
+		CurrentPassword: "Correct-Horse-42",
+		Password:        "Battery-Staple-97",
+	}, client)
+	if err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if changed.TokenResponse == nil {
+		t.Fatal("change password issued no tokens")
+	}
+
+	if _, err := ts.ValidateToken(changed.AccessToken); err != nil {
+		t.Errorf("token issued with the new password: %v", err)
+	}
+	if _, err := ts.ValidateToken(before.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("token issued before the change: got %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestRevokeUserTokensKeepsTokensOfTheSameSecond(t *testing.T) {
+	ts := newTestUserService(t)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	// Whatever the clock, the revocation is stored at a whole second, the
+	// precision tokens carry their iat in.
+	if err := ts.revokeUserTokens(user.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	revokedAt := ts.revocations.revocations[0].CreatedAt
+	if !revokedAt.Equal(revokedAt.Truncate(time.Second)) {
+		t.Fatalf("revocation created at %v, want a whole second", revokedAt)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"earlier second", revokedAt.Add(-time.Second), true},
+		{"same second", revokedAt, false},
+		{"later second", revokedAt.Add(time.Second), false},
+	} {
+		revoked, err := ts.revocations.IsRevoked("", "", user.ID, 0, tc.issuedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != tc.revoked {
+			t.Errorf("%s: revoked = %v, want %v", tc.name, revoked, tc.revoked)
+		}
+	}
+}
+
+func TestAdminPasswordChangeSignsUserOut(t *testing.T) {
+	ts := newTestUserService(t)
+	admin := ts.addUser(t, "admin", "Correct-Horse-42", domain.RoleAdmin)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	signedIn, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "Correct-Horse-42"}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+
+	waitForNextSecond()
+	if err := ts.AdminUpdateUser(domain.Actor{UserID: admin.ID}, user.ID, &domain.AdminUpdateUserRequest{
+		Password: "Battery-Staple-97",
+	}); err != nil {
+		t.Fatalf("admin update: %v", err)
+	}
+
+	if _, err := ts.ValidateToken(signedIn.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("token issued before the change: got %v, want %v", err, ErrTokenRevoked)
+	}
+	for _, session := range ts.sessions.sessions {
+		if session.UserID == user.ID && session.RevokedAt == nil {
+			t.Errorf("session %d is still active", session.ID)
+		}
+	}
+}
+
+func TestAuditDetailsLeaveOutUsernames(t *testing.T) {
+	ts := newTestUserService(t)
+	admin := ts.addUser(t, "admin", "Correct-Horse-42", domain.RoleAdmin)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	if _, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "wrong"}, domain.ClientInfo{}); err != ErrInvalidCredentials {
+		t.Fatalf("sign in with a wrong password: got %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := ts.DeleteUser(domain.Actor{UserID: admin.ID}, user.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if len(ts.audit.events) == 0 {
+		t.Fatal("nothing was audited")
+	}
+	for _, event := range ts.audit.events {
+		if strings.Contains(event.Details, "jsmith") {
+			t.Errorf("%s entry names the account: %q", event.Action, event.Details)
+		}
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	ts := newTestUserService(t)
+	ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	signedIn, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "Correct-Horse-42"}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	rotated, err := ts.RefreshToken(&domain.RefreshTokenRequest{RefreshToken: signedIn.RefreshToken}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	// Replaying the exchanged token gives the family away.
+	if _, err := ts.RefreshToken(&domain.RefreshTokenRequest{RefreshToken: signedIn.RefreshToken}, domain.ClientInfo{}); err != ErrTokenReused {
+		t.Fatalf("replay: got %v, want %v", err, ErrTokenReused)
+	}
+	if session := ts.sessions.sessions[0]; session.RevokedAt == nil {
+		t.Error("session is still active after the replay")
+	}
+	if _, err := ts.ValidateToken(rotated.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("access token issued by the rotation: got %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := ts.RefreshToken(&domain.RefreshTokenRequest{RefreshToken: rotated.RefreshToken}, domain.ClientInfo{}); err != ErrInvalidToken {
+		t.Errorf("refresh token issued by the rotation: got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestSignOutEndsEveryTokenOfTheSession(t *testing.T) {
+	ts := newTestUserService(t)
+	user := ts.addUser(t, "jsmith", "Correct-Horse-42", domain.RoleUser)
+
+	signedIn, err := ts.SignIn(&domain.SignInRequest{Username: "jsmith", Password: "Correct-Horse-42"}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	refreshed, err := ts.RefreshToken(&domain.RefreshTokenRequest{RefreshToken: signedIn.RefreshToken}, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	session := ts.sessions.sessions[0]
+	if err := ts.SignOut(domain.Actor{UserID: user.ID, SessionID: session.ID}, session.ID, ""); err != nil {
+		t.Fatalf("sign out: %v", err)
+	}
+	for name, token := range map[string]string{"first": signedIn.AccessToken, "refreshed": refreshed.AccessToken} {
+		if _, err := ts.ValidateToken(token); err != ErrTokenRevoked {
+			t.Errorf("%s access token: got %v, want %v", name, err, ErrTokenRevoked)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed common.txt
+var commonPasswords string
+
+// Blocklist holds breached passwords, either in clear text or as SHA-1
+// hashes in the "HASH:count" format of the Have I Been Pwned downloads.
+// Clear text entries match case-insensitively.
+type Blocklist struct {
+	plain  map[string]struct{}
+	hashes map[string]struct{}
+}
+
+// NewBlocklist returns the built-in list of the most common passwords.
+func NewBlocklist() *Blocklist {
+	b := &Blocklist{
+		plain:  map[string]struct{}{},
+		hashes: map[string]struct{}{},
+	}
+	_ = b.Load(strings.NewReader(commonPasswords))
+	return b
+}
+
+// LoadFile adds the entries of a local breached-password file.
+func (b *Blocklist) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return b.Load(file)
+}
+
+func (b *Blocklist) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			b.hashes[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		b.plain[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+func (b *Blocklist) Contains(password string) bool {
+	if _, ok := b.plain[strings.ToLower(password)]; ok {
+		return true
+	}
+	if len(b.hashes) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	_, ok := b.hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
+
+func isSHA1(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,136 @@
+// Package password checks new passwords against a configurable policy and a
+// list of known breached passwords.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Policy describes what a new password must look like.
+type Policy struct {
+	MinLength int
+	// MinClasses is how many of lower case, upper case, digits and symbols
+	// the password must mix.
+	MinClasses int
+	// HistorySize is how many previous passwords may not be reused.
+	HistorySize int
+	Blocklist   *Blocklist
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinLength:   10,
+		MinClasses:  3,
+		HistorySize: 5,
+	}
+}
+
+// Check returns every rule the password breaks, or nil if it is
+// acceptable. Password history is checked separately since it needs the
+// stored hashes.
+func (p *Policy) Check(password, username string) []string {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if classes := countClasses(password); classes < p.MinClasses {
+		violations = append(violations, fmt.Sprintf("must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses))
+	}
+
+	if username != "" && similar(password, username) {
+		violations = append(violations, "must not resemble the username")
+	}
+
+	if p.Blocklist != nil && p.Blocklist.Contains(password) {
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+
+	return violations
+}
+
+func countClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// similar reports whether the password is built around the username:
+// containing it forwards or backwards, or being a few edits away from it.
+func similar(password, username string) bool {
+	p := normalize(password)
+	u := normalize(username)
+	// A password of symbols alone has nothing left to compare, and every
+	// string contains the empty one.
+	if p == "" {
+		return false
+	}
+	if len(u) < 3 {
+		return p == u
+	}
+
+	if strings.Contains(p, u) || strings.Contains(p, reverse(u)) || strings.Contains(u, p) {
+		return true
+	}
+	return levenshtein(p, u) <= 2
+}
+
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,21 @@
+package password
+
+import "testing"
+
+func TestSimilar(t *testing.T) {
+	for _, tc := range []struct {
+		password, username string
+		want               bool
+	}{
+		{"jsmith2024!", "jsmith", true},
+		{"Htims-J-99", "j.smith", true},
+		{"jsmiht", "jsmith", true},
+		{"Correct-Horse-42", "jsmith", false},
+		{"!@#$%^&*()_+", "jsmith", false},
+		{"!@#$%^&*()_+", "js", false},
+	} {
+		if got := similar(tc.password, tc.username); got != tc.want {
+			t.Errorf("similar(%q, %q) = %v, want %v", tc.password, tc.username, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package scim parses the filter and attribute path expressions of SCIM 2.0
+// (RFC 7644, section 3.4.2.2 and 3.5.2).
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidPath   = errors.New("invalid path")
+)
+
+// Comparison operators.
+const (
+	OpEqual          = "eq"
+	OpNotEqual       = "ne"
+	OpContains       = "co"
+	OpStartsWith     = "sw"
+	OpEndsWith       = "ew"
+	OpPresent        = "pr"
+	OpGreater        = "gt"
+	OpGreaterOrEqual = "ge"
+	OpLess           = "lt"
+	OpLessOrEqual    = "le"
+)
+
+// Expr is a parsed filter: a Comparison, a Logical or a Not.
+type Expr interface {
+	expr()
+}
+
+// Comparison tests one attribute. Attr is lower case, without a schema
+// URN, and uses dots for sub-attributes ("name.givenname"). Value is a
+// string, float64, bool or nil, and is unused for OpPresent.
+type Comparison struct {
+	Attr  string
+	Op    string
+	Value interface{}
+}
+
+// Logical joins two filters with "and" or "or".
+type Logical struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+func (Comparison) expr() {}
+func (Logical) expr()    {}
+func (Not) expr()        {}
+
+// Path is the target of a PATCH operation: an attribute, optionally
+// narrowed to the values matching Filter, and optionally a sub-attribute of
+// those values, as in `emails[type eq "work"].value`.
+type Path struct {
+	Attr    string
+	Filter  Expr
+	SubAttr string
+}
+
+// Parse parses a filter such as `userName eq "jdoe" and active eq true`.
+// Value filters (`emails[type eq "work"]`) are flattened into comparisons
+// on the sub-attributes.
+func Parse(filter string) (Expr, error) {
+	tokens, err := tokenize(filter, ErrInvalidFilter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, err: ErrInvalidFilter}
+	expr, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return expr, nil
+}
+
+// ParsePath parses the path of a PATCH operation.
+func ParsePath(path string) (*Path, error) {
+	tokens, err := tokenize(path, ErrInvalidPath)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, err: ErrInvalidPath}
+	if p.done() || p.peek().kind != tokenWord {
+		return nil, p.errorf("attribute expected")
+	}
+
+	result := &Path{Attr: normalizeAttr(p.next().text)}
+	if !p.done() && p.peek().kind == tokenOpenBracket {
+		p.next()
+		if result.Filter, err = p.parseOr(""); err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseBracket {
+			return nil, p.errorf("missing ]")
+		}
+		if !p.done() {
+			sub := p.next()
+			if sub.kind != tokenWord || !strings.HasPrefix(sub.text, ".") {
+				return nil, p.errorf("unexpected %q", sub.text)
+			}
+			result.SubAttr = strings.ToLower(strings.TrimPrefix(sub.text, "."))
+		}
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return result, nil
+}
+
+// normalizeAttr lower-cases an attribute path and drops its schema URN, so
+// "urn:ietf:params:scim:schemas:core:2.0:User:userName" becomes "username".
+func normalizeAttr(attr string) string {
+	attr = strings.ToLower(attr)
+	if strings.HasPrefix(attr, "urn:") {
+		attr = attr[strings.LastIndex(attr, ":")+1:]
+	}
+	return attr
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpenParen
+	tokenCloseParen
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string, kind error) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpenParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenCloseParen, ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokenOpenBracket, "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokenCloseBracket, "]"})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated string", kind)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("%w: bad string %s", kind, s[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	err    error
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", p.err, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peekKeyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, keyword)
+}
+
+// parseOr parses filters joined by "or", which binds weaker than "and".
+// Inside a value filter, prefix is the attribute the brackets belong to.
+func (p *parser) parseOr(prefix string) (Expr, error) {
+	left, err := p.parseAnd(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(prefix string) (Expr, error) {
+	left, err := p.parseTerm(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseTerm(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm(prefix string) (Expr, error) {
+	if p.done() {
+		return nil, p.errorf("unexpected end")
+	}
+
+	if p.peekKeyword("not") {
+		p.next()
+		if p.done() || p.next().kind != tokenOpenParen {
+			return nil, p.errorf("( expected after not")
+		}
+		inner, err := p.parseOr(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseParen {
+			return nil, p.errorf("missing )")
+		}
+		return Not{Expr: inner}, nil
+	}
+
+	if p.peek().kind == tokenOpenParen {
+		p.next()
+		inner, err := p.parseOr(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseParen {
+			return nil, p.errorf("missing )")
+		}
+		return inner, nil
+	}
+
+	attrToken := p.next()
+	if attrToken.kind != tokenWord {
+		return nil, p.errorf("attribute expected, got %q", attrToken.text)
+	}
+	attr := normalizeAttr(attrToken.text)
+	if prefix != "" {
+		attr = prefix + "." + attr
+	}
+
+	if !p.done() && p.peek().kind == tokenOpenBracket {
+		if prefix != "" {
+			return nil, p.errorf("nested value filters are not allowed")
+		}
+		p.next()
+		inner, err := p.parseOr(attr)
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.next().kind != tokenCloseBracket {
+			return nil, p.errorf("missing ]")
+		}
+		return inner, nil
+	}
+
+	if p.done() || p.peek().kind != tokenWord {
+		return nil, p.errorf("operator expected after %s", attrToken.text)
+	}
+	op := strings.ToLower(p.next().text)
+	switch op {
+	case OpPresent:
+		return Comparison{Attr: attr, Op: op}, nil
+	case OpEqual, OpNotEqual, OpContains, OpStartsWith, OpEndsWith,
+		OpGreater, OpGreaterOrEqual, OpLess, OpLessOrEqual:
+	default:
+		return nil, p.errorf("unknown operator %q", op)
+	}
+
+	if p.done() {
+		return nil, p.errorf("value expected after %s", op)
+	}
+	valueToken := p.next()
+	if valueToken.kind == tokenString {
+		return Comparison{Attr: attr, Op: op, Value: valueToken.text}, nil
+	}
+	if valueToken.kind != tokenWord {
+		return nil, p.errorf("value expected after %s", op)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(strings.ToLower(valueToken.text)), &value); err != nil {
+		return nil, p.errorf("bad value %q", valueToken.text)
+	}
+	switch value.(type) {
+	case bool, float64, nil:
+	default:
+		return nil, p.errorf("bad value %q", valueToken.text)
+	}
+	return Comparison{Attr: attr, Op: op, Value: value}, nil
+}
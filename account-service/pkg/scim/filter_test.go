@@ -0,0 +1,168 @@
+package scim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		filter string
+		want   Expr
+	}{
+		{
+			`userName eq "jdoe"`,
+			Comparison{Attr: "username", Op: OpEqual, Value: "jdoe"},
+		},
+		{
+			`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "J"`,
+			Comparison{Attr: "username", Op: OpStartsWith, Value: "J"},
+		},
+		{
+			`name.givenName co "an\"n"`,
+			Comparison{Attr: "name.givenname", Op: OpContains, Value: `an"n`},
+		},
+		{
+			`emails pr`,
+			Comparison{Attr: "emails", Op: OpPresent},
+		},
+		{
+			`active eq True and id gt 10`,
+			Logical{
+				Op:    "and",
+				Left:  Comparison{Attr: "active", Op: OpEqual, Value: true},
+				Right: Comparison{Attr: "id", Op: OpGreater, Value: float64(10)},
+			},
+		},
+		{
+			// and binds tighter than or.
+			`a eq 1 or b eq 2 and c eq 3`,
+			Logical{
+				Op:   "or",
+				Left: Comparison{Attr: "a", Op: OpEqual, Value: float64(1)},
+				Right: Logical{
+					Op:    "and",
+					Left:  Comparison{Attr: "b", Op: OpEqual, Value: float64(2)},
+					Right: Comparison{Attr: "c", Op: OpEqual, Value: float64(3)},
+				},
+			},
+		},
+		{
+			`(a eq 1 or b eq 2) and not (c eq null)`,
+			Logical{
+				Op: "and",
+				Left: Logical{
+					Op:    "or",
+					Left:  Comparison{Attr: "a", Op: OpEqual, Value: float64(1)},
+					Right: Comparison{Attr: "b", Op: OpEqual, Value: float64(2)},
+				},
+				Right: Not{Expr: Comparison{Attr: "c", Op: OpEqual, Value: nil}},
+			},
+		},
+		{
+			`emails[type eq "work" and value ew "@example.org"]`,
+			Logical{
+				Op:    "and",
+				Left:  Comparison{Attr: "emails.type", Op: OpEqual, Value: "work"},
+				Right: Comparison{Attr: "emails.value", Op: OpEndsWith, Value: "@example.org"},
+			},
+		},
+	} {
+		got, err := Parse(tc.filter)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.filter, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tc.filter, got, tc.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName is "jdoe"`,
+		`userName eq "jdoe`,
+		`userName eq jdoe`,
+		`(userName eq "jdoe"`,
+		`not userName eq "jdoe"`,
+		`userName eq "jdoe" and`,
+		`userName eq "jdoe" extra`,
+		`emails[type eq "work"`,
+		`emails[type[primary eq true]]`,
+	} {
+		if _, err := Parse(filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Parse(%q): got %v, want %v", filter, err, ErrInvalidFilter)
+		}
+	}
+}
+
+// The filter of a path is relative to the attribute it selects values of.
+func TestParsePath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want *Path
+	}{
+		{`userName`, &Path{Attr: "username"}},
+		{`name.familyName`, &Path{Attr: "name.familyname"}},
+		{
+			`emails[type eq "work"].value`,
+			&Path{
+				Attr:    "emails",
+				Filter:  Comparison{Attr: "type", Op: OpEqual, Value: "work"},
+				SubAttr: "value",
+			},
+		},
+		{
+			`members[value eq "42"]`,
+			&Path{
+				Attr:   "members",
+				Filter: Comparison{Attr: "value", Op: OpEqual, Value: "42"},
+			},
+		},
+	} {
+		got, err := ParsePath(tc.path)
+		if err != nil {
+			t.Errorf("ParsePath(%q): %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParsePath(%q) = %#v, want %#v", tc.path, got, tc.want)
+		}
+	}
+
+	for _, path := range []string{``, `"userName"`, `emails[type eq "work"`, `emails[type eq "work"]value`, `userName extra`} {
+		if _, err := ParsePath(path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("ParsePath(%q): got %v, want %v", path, err, ErrInvalidPath)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	attrs := map[string][]string{
+		"username": {"JDoe"},
+		"emails":   {"jdoe@example.org", "john@home.example"},
+		"id":       {"42"},
+	}
+	for filter, want := range map[string]bool{
+		`userName eq "jdoe"`:                      true,
+		`emails ew "@home.example"`:               true,
+		`emails eq "nobody@example.org"`:          false,
+		`title pr`:                                false,
+		`title ne "x"`:                            true,
+		`userName eq "jdoe" and not (id eq "42")`: false,
+		`userName eq "x" or id eq "42"`:           true,
+	} {
+		expr, err := Parse(filter)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", filter, err)
+		}
+		if got := Match(expr, attrs); got != want {
+			t.Errorf("Match(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Match evaluates a filter against a resource held in memory. attrs maps
+// lower-case attribute paths to their values; a comparison on a
+// multi-valued attribute holds if any value satisfies it. Values compare as
+// strings, ignoring case.
+func Match(expr Expr, attrs map[string][]string) bool {
+	switch e := expr.(type) {
+	case Logical:
+		if e.Op == "or" {
+			return Match(e.Left, attrs) || Match(e.Right, attrs)
+		}
+		return Match(e.Left, attrs) && Match(e.Right, attrs)
+	case Not:
+		return !Match(e.Expr, attrs)
+	case Comparison:
+		want := ""
+		if e.Value != nil {
+			want = strings.ToLower(fmt.Sprint(e.Value))
+		}
+		for _, value := range attrs[e.Attr] {
+			if compare(strings.ToLower(value), e.Op, want) {
+				return true
+			}
+		}
+		// ne holds for an attribute without values.
+		return e.Op == OpNotEqual && len(attrs[e.Attr]) == 0
+	}
+	return false
+}
+
+// Attributes lists the attribute paths a filter refers to.
+func Attributes(expr Expr) []string {
+	switch e := expr.(type) {
+	case Logical:
+		return append(Attributes(e.Left), Attributes(e.Right)...)
+	case Not:
+		return Attributes(e.Expr)
+	case Comparison:
+		return []string{e.Attr}
+	}
+	return nil
+}
+
+func compare(have, op, want string) bool {
+	switch op {
+	case OpEqual:
+		return have == want
+	case OpNotEqual:
+		return have != want
+	case OpContains:
+		return strings.Contains(have, want)
+	case OpStartsWith:
+		return strings.HasPrefix(have, want)
+	case OpEndsWith:
+		return strings.HasSuffix(have, want)
+	case OpPresent:
+		return have != ""
+	case OpGreater:
+		return have > want
+	case OpGreaterOrEqual:
+		return have >= want
+	case OpLess:
+		return have < want
+	case OpLessOrEqual:
+		return have <= want
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package auth
+
+import "github.com/golang-jwt/jwt/v5"
+
+type Role string
+
+const (
+	RoleAdmin   Role = "Admin"
+	RoleManager Role = "Manager"
+	RoleDoctor  Role = "Doctor"
+	RoleUser    Role = "User"
+)
+
+// Valid reports whether r is one of the roles above.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleManager, RoleDoctor, RoleUser:
+		return true
+	}
+	return false
+}
+
+// Claims is the payload of every access token account-service issues. A
+// user may hold several roles, so they are always carried as a list.
+// Tokens issued to service clients have no UserID; they carry ClientID and
+// a space-separated Scope instead. Impersonation tokens name the user being
+// impersonated in UserID and Roles and the admin behind them in Act.
+type Claims struct {
+	UserID    uint        `json:"user_id"`
+	Roles     []Role      `json:"roles"`
+	SessionID uint        `json:"sid,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Scope     string      `json:"scope,omitempty"`
+	Act       *ActorClaim `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActorClaim is the "act" claim of RFC 8693: who is really acting when a
+// token is used on someone else's behalf. SessionID is the actor's own
+// session, so signing them out ends the impersonation too.
+type ActorClaim struct {
+	UserID    uint `json:"user_id"`
+	SessionID uint `json:"sid,omitempty"`
+}
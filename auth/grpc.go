@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor authenticates every unary call from its
+// "authorization" metadata and stores the Principal on the call context.
+// Calls made while impersonating are logged.
+func UnaryServerInterceptor(verifier *Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, ErrMissingToken.Error())
+		}
+
+		principal, err := verifier.Verify(values[0])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if principal.IsImpersonated() {
+			logImpersonation(principal, info.FullMethod)
+		}
+
+		return handler(NewContext(ctx, principal), req)
+	}
+}
+
+// CheckRole returns a PermissionDenied error unless the caller on ctx has at
+// least one of the given roles.
+func CheckRole(ctx context.Context, roles ...Role) error {
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, ErrMissingToken.Error())
+	}
+	if !principal.HasRole(roles...) {
+		return status.Error(codes.PermissionDenied, "insufficient role")
+	}
+	return nil
+}
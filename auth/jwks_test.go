@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKSRefreshGivesUpOnAHangingServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	keys := newJWKSCache(server.URL)
+	keys.client.Timeout = 50 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := keys.key("kid")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("got a key from a server that never answered")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("key lookup is still waiting for the server")
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AnyRole marks a route that every authenticated caller may use.
+var AnyRole = []Role{RoleAdmin, RoleManager, RoleDoctor, RoleUser}
+
+// Permissions maps a route to the roles allowed to call it. HTTP routes are
+// keyed by method and gin route pattern ("GET /api/Hospitals/:id"), gRPC
+// methods by their full name ("/hospital.HospitalService/GetHospital").
+// Anything missing from the matrix is denied.
+type Permissions map[string][]Role
+
+func (p Permissions) allows(route string, principal *Principal) bool {
+	roles, ok := p[route]
+	if !ok {
+		return false
+	}
+	return principal.HasRole(roles...)
+}
+
+// Authorize checks the caller against the matrix entry of the matched
+// route. It must run after Middleware.
+func Authorize(permissions Permissions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalFromGin(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrMissingToken.Error()})
+			return
+		}
+
+		if !permissions.allows(c.Request.Method+" "+c.FullPath(), principal) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UnaryAuthorizeInterceptor is the gRPC counterpart of Authorize. It must be
+// chained after UnaryServerInterceptor.
+func UnaryAuthorizeInterceptor(permissions Permissions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		principal, ok := FromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, ErrMissingToken.Error())
+		}
+
+		if !permissions.allows(info.FullMethod, principal) {
+			return nil, status.Error(codes.PermissionDenied, "insufficient role")
+		}
+
+		return handler(ctx, req)
+	}
+}
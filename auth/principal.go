@@ -0,0 +1,77 @@
+// Package auth verifies access tokens issued by account-service and exposes
+// the authenticated caller to gin handlers and gRPC methods.
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request: either a user or,
+// when ClientID is set, a registered service client. When an admin
+// impersonates a user, UserID and Roles are the user's and Actor names the
+// admin.
+type Principal struct {
+	UserID    uint
+	Roles     []Role
+	SessionID uint
+	ClientID  string
+	Scopes    []string
+	TokenID   string
+	Token     string
+	Actor     *ActorClaim
+}
+
+// NewPrincipal builds the Principal for already verified claims.
+func NewPrincipal(claims *Claims, token string) *Principal {
+	return &Principal{
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		SessionID: claims.SessionID,
+		ClientID:  claims.ClientID,
+		Scopes:    strings.Fields(claims.Scope),
+		TokenID:   claims.ID,
+		Token:     token,
+		Actor:     claims.Act,
+	}
+}
+
+func (p *Principal) IsClient() bool {
+	return p.ClientID != ""
+}
+
+// IsImpersonated reports whether an admin is acting as the user.
+func (p *Principal) IsImpersonated() bool {
+	return p.Actor != nil
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, have := range p.Scopes {
+		if have == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasRole(roles ...Role) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
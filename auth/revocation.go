@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const revocationRefreshInterval = 30 * time.Second
+
+type revokedUser struct {
+	UserID    uint      `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type revocationList struct {
+	JTIs     []string      `json:"jtis"`
+	Sessions []uint        `json:"sessions"`
+	Clients  []string      `json:"clients"`
+	Users    []revokedUser `json:"users"`
+}
+
+// revocationCache keeps a local copy of account-service's revocation list so
+// a revoked token is rejected without a round trip on every request. The
+// copy is refreshed lazily; if account-service is unreachable the last
+// known list keeps being used.
+type revocationCache struct {
+	url       string
+	client    *http.Client
+	mu        sync.RWMutex
+	jtis      map[string]struct{}
+	sessions  map[uint]struct{}
+	clients   map[string]struct{}
+	users     map[uint]time.Time
+	fetchedAt time.Time
+}
+
+func newRevocationCache(baseURL string, credentials *ClientCredentials) *revocationCache {
+	client := &http.Client{Timeout: 5 * time.Second}
+	if credentials != nil {
+		client.Transport = credentials.Transport(nil)
+	}
+	return &revocationCache{
+		url:      fmt.Sprintf("%s/api/Authentication/Revocations", baseURL),
+		client:   client,
+		jtis:     map[string]struct{}{},
+		sessions: map[uint]struct{}{},
+		clients:  map[string]struct{}{},
+		users:    map[uint]time.Time{},
+	}
+}
+
+// isRevoked rejects a token if it, its session, its client or its user was
+// revoked.
+// For impersonation tokens the acting admin's session and user count too.
+func (r *revocationCache) isRevoked(claims *Claims) bool {
+	r.refreshIfStale()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.jtis[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	if _, ok := r.clients[claims.ClientID]; ok && claims.ClientID != "" {
+		return true
+	}
+	if r.revokedLocked(claims.UserID, claims.SessionID, claims.IssuedAt) {
+		return true
+	}
+	return claims.Act != nil && r.revokedLocked(claims.Act.UserID, claims.Act.SessionID, claims.IssuedAt)
+}
+
+func (r *revocationCache) revokedLocked(userID, sessionID uint, issuedAt *jwt.NumericDate) bool {
+	if _, ok := r.sessions[sessionID]; ok && sessionID != 0 {
+		return true
+	}
+	if revokedAt, ok := r.users[userID]; ok {
+		if issuedAt == nil || issuedAt.Before(revokedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *revocationCache) refreshIfStale() {
+	r.mu.RLock()
+	fresh := time.Since(r.fetchedAt) < revocationRefreshInterval
+	r.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	list, err := r.fetch()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Even a failed fetch waits for the next interval, so an outage of
+	// account-service does not turn into a request per incoming call.
+	r.fetchedAt = time.Now()
+	if err != nil {
+		return
+	}
+
+	r.jtis = make(map[string]struct{}, len(list.JTIs))
+	for _, jti := range list.JTIs {
+		r.jtis[jti] = struct{}{}
+	}
+	r.sessions = make(map[uint]struct{}, len(list.Sessions))
+	for _, sessionID := range list.Sessions {
+		r.sessions[sessionID] = struct{}{}
+	}
+	r.clients = make(map[string]struct{}, len(list.Clients))
+	for _, clientID := range list.Clients {
+		r.clients[clientID] = struct{}{}
+	}
+	r.users = make(map[uint]time.Time, len(list.Users))
+	for _, user := range list.Users {
+		if user.RevokedAt.After(r.users[user.UserID]) {
+			r.users[user.UserID] = user.RevokedAt
+		}
+	}
+}
+
+func (r *revocationCache) fetch() (*revocationList, error) {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revocation list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch revocation list: %s", resp.Status)
+	}
+
+	var list revocationList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode revocation list: %w", err)
+	}
+	return &list, nil
+}
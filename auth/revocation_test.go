@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRevokedUserKeepsTokensOfTheSameSecond(t *testing.T) {
+	revokedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(revocationList{
+			Users: []revokedUser{{UserID: 7, RevokedAt: revokedAt}},
+		})
+	}))
+	defer server.Close()
+
+	cache := newRevocationCache(server.URL, nil)
+	for _, tc := range []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"earlier second", revokedAt.Add(-time.Second), true},
+		{"same second", revokedAt, false},
+		{"later second", revokedAt.Add(time.Second), false},
+	} {
+		claims := &Claims{
+			UserID:           7,
+			RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(tc.issuedAt)},
+		}
+		if got := cache.isRevoked(claims); got != tc.revoked {
+			t.Errorf("%s: revoked = %v, want %v", tc.name, got, tc.revoked)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ScopeRevocations is the scope a service client needs to fetch
+// account-service's revocation list.
+const ScopeRevocations = "revocations"
+
+var (
+	ErrMissingToken = errors.New("authorization token required")
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// Verifier checks access tokens offline against the keys account-service
+// publishes and its revocation list.
+type Verifier struct {
+	keys        *jwksCache
+	revocations *revocationCache
+}
+
+// NewVerifier creates a Verifier for the account-service reachable at
+// accountServiceURL. The revocation list is only given to service clients
+// with the ScopeRevocations scope, so credentials should belong to such a
+// client; without them revoked tokens keep being accepted until they
+// expire.
+func NewVerifier(accountServiceURL string, credentials *ClientCredentials) *Verifier {
+	return &Verifier{
+		keys:        newJWKSCache(accountServiceURL),
+		revocations: newRevocationCache(accountServiceURL, credentials),
+	}
+}
+
+// Verify validates a raw token or an Authorization header value.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims, err := verifyToken(token, v.keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if v.revocations.isRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+
+	return NewPrincipal(claims, token), nil
+}
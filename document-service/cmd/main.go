@@ -11,11 +11,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	httpHandler "github.com/sergeimurashev/hospital-system-api/document-service/internal/delivery/http"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/repository"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/service"
-	"github.com/sergeimurashev/hospital-system-api/document-service/pkg/auth"
 	"github.com/sergeimurashev/hospital-system-api/document-service/pkg/elasticsearch"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -44,16 +44,29 @@ func main() {
 
 	documentRepo := repository.NewDocumentRepository(db)
 
-	authClient := auth.NewClient()
+	// The revocation list is only served to a service client with the
+	// revocations scope.
+	var credentials *auth.ClientCredentials
+	if clientID := os.Getenv("SERVICE_CLIENT_ID"); clientID != "" {
+		credentials = auth.NewClientCredentials(
+			os.Getenv("ACCOUNT_SERVICE_URL"),
+			clientID,
+			os.Getenv("SERVICE_CLIENT_SECRET"),
+			auth.ScopeRevocations,
+		)
+	} else {
+		log.Printf("Warning: SERVICE_CLIENT_ID not set, revoked tokens will be accepted until they expire")
+	}
+	verifier := auth.NewVerifier(os.Getenv("ACCOUNT_SERVICE_URL"), credentials)
 
 	esClient, err := elasticsearch.NewClient()
 	if err != nil {
 		log.Fatalf("Failed to create Elasticsearch client: %v", err)
 	}
 
-	documentService := service.NewDocumentService(documentRepo, esClient, authClient)
+	documentService := service.NewDocumentService(documentRepo, esClient)
 
-	handler := httpHandler.NewHandler(documentService, authClient)
+	handler := httpHandler.NewHandler(documentService, verifier)
 
 	router := gin.Default()
 	handler.RegisterRoutes(router)
@@ -5,20 +5,20 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/service"
-	"github.com/sergeimurashev/hospital-system-api/document-service/pkg/auth"
 )
 
 type Handler struct {
 	documentService service.DocumentService
-	authClient      auth.Client
+	verifier        *auth.Verifier
 }
 
-func NewHandler(documentService service.DocumentService, authClient auth.Client) *Handler {
+func NewHandler(documentService service.DocumentService, verifier *auth.Verifier) *Handler {
 	return &Handler{
 		documentService: documentService,
-		authClient:      authClient,
+		verifier:        verifier,
 	}
 }
 
@@ -31,17 +31,17 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 
 	api := router.Group("/api/v1")
 	{
-		history := api.Group("/history")
+		history := api.Group("/history", h.authMiddleware(), h.authorize())
 		{
-			history.GET("/account/:id", h.authMiddleware(), h.getPatientDocuments)
-			history.GET("/:id", h.authMiddleware(), h.getDocument)
-			history.POST("", h.authMiddleware(), h.createDocument)
-			history.PUT("/:id", h.authMiddleware(), h.updateDocument)
+			history.GET("/account/:id", h.getPatientDocuments)
+			history.GET("/:id", h.getDocument)
+			history.POST("", h.createDocument)
+			history.PUT("/:id", h.updateDocument)
 		}
 
-		search := api.Group("/search")
+		search := api.Group("/search", h.authMiddleware(), h.authorize())
 		{
-			search.GET("", h.authMiddleware(), h.searchDocuments)
+			search.GET("", h.searchDocuments)
 		}
 	}
 }
@@ -53,6 +53,11 @@ func (h *Handler) getPatientDocuments(c *gin.Context) {
 		return
 	}
 
+	if !canReadHistory(c, uint(patientID)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access to this history is not allowed"})
+		return
+	}
+
 	documents, err := h.documentService.GetPatientDocuments(uint(patientID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -79,6 +84,11 @@ func (h *Handler) getDocument(c *gin.Context) {
 		return
 	}
 
+	if !canReadHistory(c, document.PatientID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access to this history is not allowed"})
+		return
+	}
+
 	c.JSON(http.StatusOK, document)
 }
 
@@ -158,21 +168,19 @@ func (h *Handler) searchDocuments(c *gin.Context) {
 }
 
 func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization token required"})
-			c.Abort()
-			return
-		}
+	return auth.Middleware(h.verifier)
+}
 
-		if err := h.authClient.ValidateToken(token); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
-		}
+func (h *Handler) authorize() gin.HandlerFunc {
+	return auth.Authorize(permissions)
+}
 
-		c.Set("user_id", uint(1))
-		c.Next()
+// canReadHistory reports whether the caller may see a patient's medical
+// history: medical staff may see any, patients only their own.
+func canReadHistory(c *gin.Context, patientID uint) bool {
+	principal, ok := auth.PrincipalFromGin(c)
+	if !ok {
+		return false
 	}
+	return principal.HasRole(auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor) || principal.UserID == patientID
 }
@@ -0,0 +1,14 @@
+package http
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// permissions lists who may call each route. Patients reach the history
+// routes too, but handlers restrict them to their own records.
+var permissions = auth.Permissions{
+	"GET /api/v1/history/account/:id": auth.AnyRole,
+	"GET /api/v1/history/:id":         auth.AnyRole,
+	"POST /api/v1/history":            {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+	"PUT /api/v1/history/:id":         {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+
+	"GET /api/v1/search": {auth.RoleAdmin, auth.RoleManager, auth.RoleDoctor},
+}
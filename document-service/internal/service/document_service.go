@@ -5,7 +5,6 @@ import (
 
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/document-service/internal/repository"
-	"github.com/sergeimurashev/hospital-system-api/document-service/pkg/auth"
 	"github.com/sergeimurashev/hospital-system-api/document-service/pkg/elasticsearch"
 )
 
@@ -26,14 +25,12 @@ type DocumentService interface {
 type documentService struct {
 	repo repository.DocumentRepository
 	es   elasticsearch.Client
-	auth auth.Client
 }
 
-func NewDocumentService(repo repository.DocumentRepository, es elasticsearch.Client, auth auth.Client) DocumentService {
+func NewDocumentService(repo repository.DocumentRepository, es elasticsearch.Client) DocumentService {
 	return &documentService{
 		repo: repo,
 		es:   es,
-		auth: auth,
 	}
 }
 
@@ -7,10 +7,13 @@ import (
 	"os"
 
 	"github.com/joho/godotenv"
-	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/delivery/grpc"
+	"github.com/sergeimurashev/hospital-system-api/auth"
+	grpcDelivery "github.com/sergeimurashev/hospital-system-api/hospital-service/internal/delivery/grpc"
 	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/repository"
 	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/service"
+	"github.com/sergeimurashev/hospital-system-api/proto"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -30,8 +33,26 @@ func main() {
 
 	hospitalService := service.NewHospitalService(hospitalRepo, roomRepo)
 
-	grpcServer := grpc.NewServer()
-	hospitalServer := grpc.NewServer(hospitalService)
+	// The revocation list is only served to a service client with the
+	// revocations scope.
+	var credentials *auth.ClientCredentials
+	if clientID := os.Getenv("SERVICE_CLIENT_ID"); clientID != "" {
+		credentials = auth.NewClientCredentials(
+			os.Getenv("ACCOUNT_SERVICE_URL"),
+			clientID,
+			os.Getenv("SERVICE_CLIENT_SECRET"),
+			auth.ScopeRevocations,
+		)
+	} else {
+		log.Printf("Warning: SERVICE_CLIENT_ID not set, revoked tokens will be accepted until they expire")
+	}
+	verifier := auth.NewVerifier(os.Getenv("ACCOUNT_SERVICE_URL"), credentials)
+
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		auth.UnaryServerInterceptor(verifier),
+		auth.UnaryAuthorizeInterceptor(grpcDelivery.Permissions),
+	))
+	hospitalServer := grpcDelivery.NewServer(hospitalService)
 	proto.RegisterHospitalServiceServer(grpcServer, hospitalServer)
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("GRPC_PORT")))
@@ -40,7 +61,7 @@ func main() {
 	}
 
 	log.Printf("Starting gRPC server on port %s", os.Getenv("GRPC_PORT"))
-	if err := grpcServer.Server(lis); err != nil {
+	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
@@ -0,0 +1,13 @@
+package grpc
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// Permissions lists who may call each HospitalService method.
+var Permissions = auth.Permissions{
+	"/hospital.HospitalService/GetHospital":    auth.AnyRole,
+	"/hospital.HospitalService/ListHospitals":  auth.AnyRole,
+	"/hospital.HospitalService/GetRooms":       auth.AnyRole,
+	"/hospital.HospitalService/CreateHospital": {auth.RoleAdmin},
+	"/hospital.HospitalService/UpdateHospital": {auth.RoleAdmin},
+	"/hospital.HospitalService/DeleteHospital": {auth.RoleAdmin},
+}
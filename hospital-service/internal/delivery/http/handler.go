@@ -5,17 +5,20 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/hospital-service/internal/service"
 )
 
 type Handler struct {
 	hospitalService service.HospitalService
+	verifier        *auth.Verifier
 }
 
-func NewHandler(hospitalService service.HospitalService) *Handler {
+func NewHandler(hospitalService service.HospitalService, verifier *auth.Verifier) *Handler {
 	return &Handler{
 		hospitalService: hospitalService,
+		verifier:        verifier,
 	}
 }
 
@@ -28,14 +31,14 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 
 	api := router.Group("/api")
 	{
-		hospitals := api.Group("/Hospitals")
+		hospitals := api.Group("/Hospitals", h.authMiddleware(), h.authorize())
 		{
-			hospitals.GET("", h.authMiddleware(), h.listHospitals)
-			hospitals.GET("/:id", h.authMiddleware(), h.getHospital)
-			hospitals.GET("/:id/Rooms", h.authMiddleware(), h.getHospitalRooms)
-			hospitals.POST("", h.adminMiddleware(), h.createHospital)
-			hospitals.PUT("/:id", h.adminMiddleware(), h.updateHospital)
-			hospitals.DELETE("/:id", h.adminMiddleware(), h.deleteHospital)
+			hospitals.GET("", h.listHospitals)
+			hospitals.GET("/:id", h.getHospital)
+			hospitals.GET("/:id/Rooms", h.getHospitalRooms)
+			hospitals.POST("", h.createHospital)
+			hospitals.PUT("/:id", h.updateHospital)
+			hospitals.DELETE("/:id", h.deleteHospital)
 		}
 	}
 }
@@ -137,36 +140,9 @@ func (h *Handler) deleteHospital(c *gin.Context) {
 }
 
 func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header is required"})
-			c.Abort()
-			return
-		}
-
-		if len(token) > 7 && token[:7] == "Bearer " {
-			token = token[7:]
-		}
-
-		if err := h.hospitalService.ValidateToken(token); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
+	return auth.Middleware(h.verifier)
 }
 
-func (h *Handler) adminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		h.authMiddleware()(c)
-		if c.IsAborted() {
-			return
-		}
-
-		// TODO: Implement admin role check
-		c.Next()
-	}
+func (h *Handler) authorize() gin.HandlerFunc {
+	return auth.Authorize(permissions)
 }
@@ -0,0 +1,13 @@
+package http
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// permissions lists who may call each route.
+var permissions = auth.Permissions{
+	"GET /api/Hospitals":           auth.AnyRole,
+	"GET /api/Hospitals/:id":       auth.AnyRole,
+	"GET /api/Hospitals/:id/Rooms": auth.AnyRole,
+	"POST /api/Hospitals":          {auth.RoleAdmin},
+	"PUT /api/Hospitals/:id":       {auth.RoleAdmin},
+	"DELETE /api/Hospitals/:id":    {auth.RoleAdmin},
+}
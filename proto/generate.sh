@@ -7,4 +7,4 @@ go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
 # Generate Go code from proto files
 protoc --go_out=. --go_opt=paths=source_relative \
     --go-grpc_out=. --go-grpc_opt=paths=source_relative \
-    hospital.proto 
\ No newline at end of file
+    hospital.proto account/account.proto 
\ No newline at end of file
@@ -11,11 +11,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	httpHandler "github.com/sergeimurashev/hospital-system-api/timetable-service/internal/delivery/http"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/repository"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/service"
-	"github.com/sergeimurashev/hospital-system-api/timetable-service/pkg/auth"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -43,11 +43,24 @@ func main() {
 
 	timetableRepo := repository.NewTimetableRepository(db)
 
-	authClient := auth.NewClient()
+	// The revocation list is only served to a service client with the
+	// revocations scope.
+	var credentials *auth.ClientCredentials
+	if clientID := os.Getenv("SERVICE_CLIENT_ID"); clientID != "" {
+		credentials = auth.NewClientCredentials(
+			os.Getenv("ACCOUNT_SERVICE_URL"),
+			clientID,
+			os.Getenv("SERVICE_CLIENT_SECRET"),
+			auth.ScopeRevocations,
+		)
+	} else {
+		log.Printf("Warning: SERVICE_CLIENT_ID not set, revoked tokens will be accepted until they expire")
+	}
+	verifier := auth.NewVerifier(os.Getenv("ACCOUNT_SERVICE_URL"), credentials)
 
-	timetableService := service.NewTimetableService(timetableRepo, authClient)
+	timetableService := service.NewTimetableService(timetableRepo)
 
-	handler := httpHandler.NewHandler(timetableService, authClient)
+	handler := httpHandler.NewHandler(timetableService, verifier)
 
 	router := gin.Default()
 	handler.RegisterRoutes(router)
@@ -5,20 +5,20 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sergeimurashev/hospital-system-api/auth"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/service"
-	"github.com/sergeimurashev/hospital-system-api/timetable-service/pkg/auth"
 )
 
 type Handler struct {
 	timetableService service.TimetableService
-	authClient       auth.Client
+	verifier         *auth.Verifier
 }
 
-func NewHandler(timetableService service.TimetableService, authClient auth.Client) *Handler {
+func NewHandler(timetableService service.TimetableService, verifier *auth.Verifier) *Handler {
 	return &Handler{
 		timetableService: timetableService,
-		authClient:       authClient,
+		verifier:         verifier,
 	}
 }
 
@@ -37,15 +37,15 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 			timetables.GET("", h.listTimetables)
 			timetables.GET("/:id", h.getTimetable)
 			timetables.GET("/:id/appointments", h.getTimetableAppointments)
-			timetables.POST("", h.authMiddleware(), h.adminMiddleware(), h.createTimetable)
-			timetables.PUT("/:id", h.authMiddleware(), h.adminMiddleware(), h.updateTimetable)
-			timetables.DELETE("/:id", h.authMiddleware(), h.adminMiddleware(), h.deleteTimetable)
+			timetables.POST("", h.authMiddleware(), h.authorize(), h.createTimetable)
+			timetables.PUT("/:id", h.authMiddleware(), h.authorize(), h.updateTimetable)
+			timetables.DELETE("/:id", h.authMiddleware(), h.authorize(), h.deleteTimetable)
 		}
 
-		appointments := api.Group("/appointments")
+		appointments := api.Group("/appointments", h.authMiddleware(), h.authorize())
 		{
-			appointments.POST("/:timetableID", h.authMiddleware(), h.createAppointment)
-			appointments.DELETE("/:id", h.authMiddleware(), h.deleteAppointment)
+			appointments.POST("/:timetableID", h.createAppointment)
+			appointments.DELETE("/:id", h.deleteAppointment)
 		}
 	}
 }
@@ -216,6 +216,19 @@ func (h *Handler) deleteAppointment(c *gin.Context) {
 		return
 	}
 
+	appointment, err := h.timetableService.GetAppointment(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Staff may cancel any appointment, everyone else only their own.
+	principal, _ := auth.PrincipalFromGin(c)
+	if !principal.HasRole(auth.RoleAdmin, auth.RoleManager) && appointment.UserID != principal.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not your appointment"})
+		return
+	}
+
 	if err := h.timetableService.DeleteAppointment(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -225,29 +238,9 @@ func (h *Handler) deleteAppointment(c *gin.Context) {
 }
 
 func (h *Handler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization token required"})
-			c.Abort()
-			return
-		}
-
-		if err := h.authClient.ValidateToken(token); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
-		}
-
-		// TODO: Extract user ID from token and set it in context
-		c.Set("user_id", uint(1)) // Temporary hardcoded user ID
-		c.Next()
-	}
+	return auth.Middleware(h.verifier)
 }
 
-func (h *Handler) adminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// TODO: Check if user has admin role
-		c.Next()
-	}
+func (h *Handler) authorize() gin.HandlerFunc {
+	return auth.Authorize(permissions)
 }
@@ -0,0 +1,14 @@
+package http
+
+import "github.com/sergeimurashev/hospital-system-api/auth"
+
+// permissions lists who may call each authenticated route. Timetables can
+// be read without signing in.
+var permissions = auth.Permissions{
+	"POST /api/v1/timetables":       {auth.RoleAdmin, auth.RoleManager},
+	"PUT /api/v1/timetables/:id":    {auth.RoleAdmin, auth.RoleManager},
+	"DELETE /api/v1/timetables/:id": {auth.RoleAdmin, auth.RoleManager},
+
+	"POST /api/v1/appointments/:timetableID": auth.AnyRole,
+	"DELETE /api/v1/appointments/:id":        auth.AnyRole,
+}
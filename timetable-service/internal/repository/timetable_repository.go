@@ -12,6 +12,7 @@ type TimetableRepository interface {
 	Delete(id uint) error
 	List(offset, limit int) ([]*domain.Timetable, error)
 	GetAppointments(timetableID uint) ([]*domain.Appointment, error)
+	GetAppointment(id uint) (*domain.Appointment, error)
 	CreateAppointment(appointment *domain.Appointment) error
 	DeleteAppointment(id uint) error
 }
@@ -60,6 +61,14 @@ func (r *timetableRepository) GetAppointments(timetableID uint) ([]*domain.Appoi
 	return appointments, nil
 }
 
+func (r *timetableRepository) GetAppointment(id uint) (*domain.Appointment, error) {
+	var appointment domain.Appointment
+	if err := r.db.First(&appointment, id).Error; err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
 func (r *timetableRepository) CreateAppointment(appointment *domain.Appointment) error {
 	return r.db.Create(appointment).Error
 }
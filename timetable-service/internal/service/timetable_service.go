@@ -6,13 +6,13 @@ import (
 
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/domain"
 	"github.com/sergeimurashev/hospital-system-api/timetable-service/internal/repository"
-	"github.com/sergeimurashev/hospital-system-api/timetable-service/pkg/auth"
 )
 
 var (
-	ErrTimetableNotFound = errors.New("timetable not found")
-	ErrInvalidTimeRange  = errors.New("invalid time range")
-	ErrTimeSlotTaken     = errors.New("time slot is already taken")
+	ErrTimetableNotFound   = errors.New("timetable not found")
+	ErrInvalidTimeRange    = errors.New("invalid time range")
+	ErrTimeSlotTaken       = errors.New("time slot is already taken")
+	ErrAppointmentNotFound = errors.New("appointment not found")
 )
 
 type TimetableService interface {
@@ -22,19 +22,18 @@ type TimetableService interface {
 	DeleteTimetable(id uint) error
 	ListTimetables(offset, limit int) ([]*domain.Timetable, error)
 	GetAppointments(timetableID uint) ([]*domain.Appointment, error)
+	GetAppointment(id uint) (*domain.Appointment, error)
 	CreateAppointment(timetableID uint, userID uint, time time.Time) error
 	DeleteAppointment(id uint) error
 }
 
 type timetableService struct {
 	repo repository.TimetableRepository
-	auth auth.Client
 }
 
-func NewTimetableService(repo repository.TimetableRepository, auth auth.Client) TimetableService {
+func NewTimetableService(repo repository.TimetableRepository) TimetableService {
 	return &timetableService{
 		repo: repo,
-		auth: auth,
 	}
 }
 
@@ -72,6 +71,14 @@ func (s *timetableService) GetAppointments(timetableID uint) ([]*domain.Appointm
 	return s.repo.GetAppointments(timetableID)
 }
 
+func (s *timetableService) GetAppointment(id uint) (*domain.Appointment, error) {
+	appointment, err := s.repo.GetAppointment(id)
+	if err != nil {
+		return nil, ErrAppointmentNotFound
+	}
+	return appointment, nil
+}
+
 func (s *timetableService) CreateAppointment(timetableID uint, userID uint, time time.Time) error {
 	timetable, err := s.repo.GetByID(timetableID)
 	if err != nil {